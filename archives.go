@@ -0,0 +1,185 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/zmb3/spotify"
+)
+
+// ArchiveOptions turns on archiving for a region. Name and Yearly override
+// the names in the config, without a Yearly name there's no yearly
+// collection.
+type ArchiveOptions struct {
+	Name   string
+	Yearly string
+}
+
+// Archive is a playlist created to snapshot a region's week, or the region's
+// yearly collection of them.
+type Archive struct {
+	Region     string
+	Title      string
+	PlaylistId spotify.ID
+	WeekStart  time.Time
+	Yearly     bool `json:",omitempty"`
+}
+
+// PlanArchives snapshots the week's tracks into the week's archive, which is
+// replaced if the week is run again, and adds them to the yearly collection
+// which only ever grows.
+func PlanArchives(spotifyClient MusicService, user string, names NamesConfig, region *Region, week Window, tracks []PlannedTrack) (plans []*PlaylistPlan, err error) {
+	name := NewPlaylistName(region.Region, "", week.From)
+
+	nameTemplate := names.Archive
+	if region.Archive.Name != "" {
+		nameTemplate = region.Archive.Name
+	}
+
+	title, err := RenderPlaylistName(nameTemplate, name)
+	if err != nil {
+		return nil, err
+	}
+
+	weekly, err := PlanArchive(spotifyClient, user, title, tracks, region.Order)
+	if err != nil {
+		return nil, err
+	}
+	weekly.Archive = &Archive{Region: region.Region, Title: title, WeekStart: name.WeekStart}
+	plans = append(plans, weekly)
+
+	yearlyTemplate := names.Yearly
+	if region.Archive.Yearly != "" {
+		yearlyTemplate = region.Archive.Yearly
+	}
+	if yearlyTemplate == "" {
+		return
+	}
+
+	title, err = RenderPlaylistName(yearlyTemplate, name)
+	if err != nil {
+		return nil, err
+	}
+
+	yearly, err := PlanArchive(spotifyClient, user, title, tracks, "")
+	if err != nil {
+		return nil, err
+	}
+	yearly.Remove = make([]PlannedTrack, 0)
+	yearly.Archive = &Archive{Region: region.Region, Title: title, WeekStart: name.WeekStart, Yearly: true}
+	plans = append(plans, yearly)
+
+	return
+}
+
+func PlanArchive(spotifyClient MusicService, user string, title string, tracks []PlannedTrack, order string) (*PlaylistPlan, error) {
+	playlist, err := GetPlaylistByTitle(spotifyClient, user, title)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get playlist: %v", err)
+	}
+
+	return NewPlaylistPlan(spotifyClient, user, title, playlist, tracks, order, nil)
+}
+
+func (ledger *Ledger) Archived(archive Archive) {
+	if ledger == nil {
+		return
+	}
+
+	for i, existing := range ledger.Archives {
+		if existing.PlaylistId == archive.PlaylistId {
+			ledger.Archives[i] = archive
+			return
+		}
+	}
+
+	ledger.Archives = append(ledger.Archives, archive)
+}
+
+// GetArchives groups archives by region, yearly collections first and then
+// weekly archives newest first.
+func (ledger *Ledger) GetArchives() []Archive {
+	archives := make([]Archive, len(ledger.Archives))
+	copy(archives, ledger.Archives)
+	sort.SliceStable(archives, func(i, j int) bool {
+		if archives[i].Region != archives[j].Region {
+			return archives[i].Region < archives[j].Region
+		}
+		if archives[i].Yearly != archives[j].Yearly {
+			return archives[i].Yearly
+		}
+		return archives[i].WeekStart.After(archives[j].WeekStart)
+	})
+	return archives
+}
+
+func ListArchives(w io.Writer, ledger *Ledger) {
+	for _, archive := range ledger.GetArchives() {
+		kind := "weekly"
+		if archive.Yearly {
+			kind = "yearly"
+		}
+		fmt.Fprintf(w, "%-20s %s %s  %s (%s)\n", archive.Region, kind, archive.WeekStart.Format("2006-01-02"), archive.Title, archive.PlaylistId)
+	}
+}
+
+// PruneArchives unfollows all but the newest keep weekly archives of each
+// region, which is as close to deleting a playlist as Spotify gets. Yearly
+// collections are never pruned.
+func PruneArchives(spotifyClient MusicService, user string, ledger *Ledger, keep int, dryRun bool) (err error) {
+	kept := make(map[string]int)
+	remaining := make([]Archive, 0)
+	for _, archive := range ledger.GetArchives() {
+		if archive.Yearly || kept[archive.Region] < keep {
+			if !archive.Yearly {
+				kept[archive.Region]++
+			}
+			remaining = append(remaining, archive)
+			continue
+		}
+
+		log.Printf("Pruning '%s' (%s)", archive.Title, archive.PlaylistId)
+		if dryRun {
+			remaining = append(remaining, archive)
+			continue
+		}
+
+		if unfollowErr := spotifyClient.UnfollowPlaylist(spotify.ID(user), archive.PlaylistId); unfollowErr != nil {
+			if err == nil {
+				err = fmt.Errorf("Unable to unfollow '%s': %v", archive.Title, unfollowErr)
+			}
+			remaining = append(remaining, archive)
+			continue
+		}
+		delete(ledger.Playlists, archive.PlaylistId)
+	}
+
+	ledger.Archives = remaining
+
+	return
+}
+
+// ArchivesCommand lists the archives and, with -prune, unfollows old ones.
+func ArchivesCommand(spotifyClient MusicService, user string, ledger *Ledger, args []string, dryRun bool) error {
+	flags := flag.NewFlagSet("archives", flag.ExitOnError)
+	prune := flags.Int("prune", 0, "unfollow all but this many of each region's newest weekly archives")
+	flags.Parse(args)
+
+	ListArchives(os.Stdout, ledger)
+
+	if *prune <= 0 {
+		return nil
+	}
+
+	err := PruneArchives(spotifyClient, user, ledger, *prune, dryRun)
+	if saveErr := ledger.Save(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+
+	return err
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zmb3/spotify"
+)
+
+func TestPlanArchives(t *testing.T) {
+	week := Window{
+		From: time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC),
+		To:   time.Date(2017, 6, 8, 12, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name     string
+		yearly   string
+		archive  ArchiveOptions
+		expected []string
+	}{
+		{
+			name:     "weekly",
+			expected: []string{"new haven 17/05/28"},
+		},
+		{
+			name:     "yearly from the config",
+			yearly:   "{{.Region}} {{.WeekStart.Year}}",
+			expected: []string{"new haven 17/05/28", "new haven 2017"},
+		},
+		{
+			name:     "the region's names",
+			yearly:   "{{.Region}} {{.WeekStart.Year}}",
+			archive:  ArchiveOptions{Name: "{{.Region}} week of {{.WeekStart.Format \"Jan 2\"}}", Yearly: "{{.Region}} forever"},
+			expected: []string{"new haven week of May 28", "new haven forever"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newTestCatalog()
+			old, _ := fake.FindTrack("old")
+			for _, title := range test.expected {
+				newTestPlaylist(fake, "jlewalle", "existing "+title, title, *old)
+			}
+
+			names := NewConfig().Names
+			names.Yearly = test.yearly
+			archive := test.archive
+			region := &Region{Region: "new haven", Archive: &archive}
+
+			z1, _ := fake.FindTrack("z1")
+			tracks := []PlannedTrack{NewPlannedTrack(*z1, "Zombii")}
+
+			plans, err := PlanArchives(fake, "jlewalle", names, region, week, tracks)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			titles := make([]string, 0)
+			for _, pp := range plans {
+				titles = append(titles, pp.Title)
+				if pp.Archive == nil || pp.Archive.Title != pp.Title || !pp.Archive.WeekStart.Equal(time.Date(2017, 5, 28, 0, 0, 0, 0, time.UTC)) {
+					t.Errorf("Expected '%s' to be an archive of May 28, got %+v", pp.Title, pp.Archive)
+				}
+			}
+			if !reflect.DeepEqual(titles, test.expected) {
+				t.Fatalf("Expected %v, got %v", test.expected, titles)
+			}
+
+			// The week is replaced, the yearly collection only grows.
+			if plans[0].Archive.Yearly || len(plans[0].Remove) != 1 {
+				t.Errorf("Expected the weekly archive to lose its old track, got %v", plans[0].Remove)
+			}
+			if len(plans) > 1 && (!plans[1].Archive.Yearly || len(plans[1].Remove) != 0) {
+				t.Errorf("Expected the yearly collection to keep its old track, got %v", plans[1].Remove)
+			}
+
+			ledger := NewLedger()
+			for _, pp := range plans {
+				if err := pp.Apply(fake, ledger); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if len(ledger.Archives) != len(plans) {
+				t.Errorf("Expected %d archives in the ledger, got %v", len(plans), ledger.Archives)
+			}
+		})
+	}
+}
+
+func newTestArchives(fake *FakeMusicService, ledger *Ledger) {
+	add := func(region string, title string, weekStart time.Time, yearly bool) {
+		id := spotify.ID(strings.Replace(title, " ", "-", -1))
+		newTestPlaylist(fake, "jlewalle", string(id), title)
+		ledger.Playlists[id] = make(map[spotify.ID]*LedgerEntry)
+		ledger.Archived(Archive{Region: region, Title: title, PlaylistId: id, WeekStart: weekStart, Yearly: yearly})
+	}
+
+	add("new haven", "new haven 2017", time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC), true)
+	for _, day := range []int{7, 14, 21, 28} {
+		weekStart := time.Date(2017, 5, day, 0, 0, 0, 0, time.UTC)
+		add("new haven", "new haven "+weekStart.Format("06/01/02"), weekStart, false)
+	}
+	for _, day := range []int{21, 28} {
+		weekStart := time.Date(2017, 5, day, 0, 0, 0, 0, time.UTC)
+		add("los angeles", "los angeles "+weekStart.Format("06/01/02"), weekStart, false)
+	}
+}
+
+func getTestArchiveTitles(ledger *Ledger) []string {
+	titles := make([]string, 0)
+	for _, archive := range ledger.GetArchives() {
+		titles = append(titles, archive.Title)
+	}
+	return titles
+}
+
+func TestPruneArchives(t *testing.T) {
+	tests := []struct {
+		name     string
+		keep     int
+		dryRun   bool
+		expected []string
+	}{
+		{
+			name:     "keeps as many as there are",
+			keep:     4,
+			expected: []string{"los angeles 17/05/28", "los angeles 17/05/21", "new haven 2017", "new haven 17/05/28", "new haven 17/05/21", "new haven 17/05/14", "new haven 17/05/07"},
+		},
+		{
+			name:     "keeps the newest",
+			keep:     2,
+			expected: []string{"los angeles 17/05/28", "los angeles 17/05/21", "new haven 2017", "new haven 17/05/28", "new haven 17/05/21"},
+		},
+		{
+			name:     "never prunes yearly collections",
+			keep:     1,
+			expected: []string{"los angeles 17/05/28", "new haven 2017", "new haven 17/05/28"},
+		},
+		{
+			name:     "dry run",
+			keep:     1,
+			dryRun:   true,
+			expected: []string{"los angeles 17/05/28", "los angeles 17/05/21", "new haven 2017", "new haven 17/05/28", "new haven 17/05/21", "new haven 17/05/14", "new haven 17/05/07"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := NewFakeMusicService("jlewalle")
+			ledger := NewLedger()
+			newTestArchives(fake, ledger)
+
+			if err := PruneArchives(fake, "jlewalle", ledger, test.keep, test.dryRun); err != nil {
+				t.Fatal(err)
+			}
+
+			if actual := getTestArchiveTitles(ledger); !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, actual)
+			}
+			if len(fake.Playlists) != len(test.expected) || len(ledger.Playlists) != len(test.expected) {
+				t.Errorf("Expected %d playlists left, got %d followed and %d in the ledger", len(test.expected), len(fake.Playlists), len(ledger.Playlists))
+			}
+			if test.dryRun && fake.calls != 0 {
+				t.Errorf("Expected a dry run to make no calls, made %d", fake.calls)
+			}
+		})
+	}
+}
+
+func TestPruneArchivesFailedUnfollow(t *testing.T) {
+	fake := NewFakeMusicService("jlewalle")
+	ledger := NewLedger()
+	newTestArchives(fake, ledger)
+
+	// Someone already deleted the oldest week by hand.
+	if err := fake.UnfollowPlaylist("jlewalle", "new-haven-17/05/07"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := PruneArchives(fake, "jlewalle", ledger, 2, false)
+	if err == nil || !strings.Contains(err.Error(), "Unable to unfollow 'new haven 17/05/07'") {
+		t.Errorf("Expected the failed unfollow to be reported, got %v", err)
+	}
+
+	expected := []string{"los angeles 17/05/28", "los angeles 17/05/21", "new haven 2017", "new haven 17/05/28", "new haven 17/05/21", "new haven 17/05/07"}
+	if actual := getTestArchiveTitles(ledger); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+	if _, ok := ledger.Playlists["new-haven-17/05/07"]; !ok {
+		t.Errorf("Expected the failed archive to stay in the ledger")
+	}
+	if pl, _ := fake.FindPlaylist("new-haven-17/05/14"); pl != nil {
+		t.Errorf("Expected the other old week to be unfollowed")
+	}
+}
+
+func TestArchivesCommand(t *testing.T) {
+	fake := NewFakeMusicService("jlewalle")
+	ledger := NewLedger()
+	ledger.FileName = filepath.Join(t.TempDir(), "ledger.json")
+	newTestArchives(fake, ledger)
+
+	if err := ArchivesCommand(fake, "jlewalle", ledger, []string{"-prune", "1"}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := LoadLedger(ledger.FileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"los angeles 17/05/28", "new haven 2017", "new haven 17/05/28"}
+	if actual := getTestArchiveTitles(saved); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected the pruned ledger to be saved with %v, got %v", expected, actual)
+	}
+}
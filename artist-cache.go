@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zmb3/spotify"
+)
+
+const (
+	defaultArtistCacheTTL         = 30 * 24 * time.Hour
+	defaultArtistCacheNegativeTTL = 7 * 24 * time.Hour
+)
+
+type CachedArtist struct {
+	Name      string
+	Found     bool
+	ArtistId  spotify.ID          `json:",omitempty"`
+	Artist    *spotify.FullArtist `json:",omitempty"`
+	NearMiss  *NearMiss           `json:",omitempty"`
+	MatchedAt time.Time
+}
+
+type ArtistCache struct {
+	FileName    string
+	TTL         time.Duration
+	NegativeTTL time.Duration
+	Now         func() time.Time
+	mutex       sync.Mutex
+	entries     map[string]*CachedArtist
+}
+
+func NormalizeArtistName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// ArtistNameKey is where searches for name are cached. Searches are made in a
+// market and another market may well find someone else.
+func ArtistNameKey(market string, name string) string {
+	return market + "|" + NormalizeArtistName(name)
+}
+
+func NewArtistCache(ttl time.Duration, negativeTTL time.Duration) *ArtistCache {
+	return &ArtistCache{
+		TTL:         ttl,
+		NegativeTTL: negativeTTL,
+		Now:         time.Now,
+		entries:     make(map[string]*CachedArtist),
+	}
+}
+
+func LoadArtistCache(fileName string, ttl time.Duration, negativeTTL time.Duration) (*ArtistCache, error) {
+	cache := NewArtistCache(ttl, negativeTTL)
+	cache.FileName = fileName
+
+	file, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cache, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(file, &cache.entries); err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %v", fileName, err)
+	}
+
+	expired := 0
+	for key, entry := range cache.entries {
+		if cache.IsExpired(entry) {
+			delete(cache.entries, key)
+			expired++
+		}
+	}
+
+	log.Printf("Loaded %d cached artists from %s (%d expired)", len(cache.entries), fileName, expired)
+
+	return cache, nil
+}
+
+func (cache *ArtistCache) IsExpired(entry *CachedArtist) bool {
+	ttl := cache.TTL
+	if !entry.Found {
+		ttl = cache.NegativeTTL
+	}
+	return cache.Now().Sub(entry.MatchedAt) > ttl
+}
+
+// ArtistIdKey is where artists looked up by id are cached. Ids are case
+// sensitive, so unlike names they aren't normalized.
+func ArtistIdKey(id spotify.ID) string {
+	return "spotify:artist:" + string(id)
+}
+
+func (cache *ArtistCache) get(key string) (entry *CachedArtist, ok bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	entry, ok = cache.entries[key]
+	if ok && cache.IsExpired(entry) {
+		return nil, false
+	}
+	return
+}
+
+func (cache *ArtistCache) put(key string, name string, artist *spotify.FullArtist) {
+	entry := &CachedArtist{
+		Name:      name,
+		MatchedAt: cache.Now(),
+	}
+	if artist != nil {
+		entry.Found = true
+		entry.ArtistId = artist.ID
+		entry.Artist = artist
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.entries[key] = entry
+}
+
+func (cache *ArtistCache) Get(market string, name string) (entry *CachedArtist, ok bool) {
+	return cache.get(ArtistNameKey(market, name))
+}
+
+func (cache *ArtistCache) Put(market string, name string, artist *spotify.FullArtist) {
+	cache.put(ArtistNameKey(market, name), name, artist)
+}
+
+func (cache *ArtistCache) GetById(id spotify.ID) (entry *CachedArtist, ok bool) {
+	return cache.get(ArtistIdKey(id))
+}
+
+func (cache *ArtistCache) PutById(id spotify.ID, artist *spotify.FullArtist) {
+	cache.put(ArtistIdKey(id), string(id), artist)
+}
+
+func (cache *ArtistCache) PutNearMiss(market string, name string, nearMiss *NearMiss) {
+	entry := &CachedArtist{
+		Name:      name,
+		NearMiss:  nearMiss,
+		MatchedAt: cache.Now(),
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.entries[ArtistNameKey(market, name)] = entry
+}
+
+func (cache *ArtistCache) Save() error {
+	if cache.FileName == "" {
+		return nil
+	}
+
+	cache.mutex.Lock()
+	data, err := json.MarshalIndent(cache.entries, "", "  ")
+	cache.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(cache.FileName, data, 0644)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/zmb3/spotify"
+)
+
+func TestArtistCacheIdsAreCaseSensitive(t *testing.T) {
+	cache := NewArtistCache(defaultArtistCacheTTL, defaultArtistCacheNegativeTTL)
+
+	upper := &spotify.FullArtist{SimpleArtist: spotify.SimpleArtist{ID: "0h3SPCWqv0ULBy4SNcR4Ec", Name: "Zombii"}}
+	lower := &spotify.FullArtist{SimpleArtist: spotify.SimpleArtist{ID: "0h3spcwqv0ulby4sncr4ec", Name: "Someone Else"}}
+
+	cache.PutById(upper.ID, upper)
+	cache.PutById(lower.ID, lower)
+
+	for _, expected := range []*spotify.FullArtist{upper, lower} {
+		cached, ok := cache.GetById(expected.ID)
+		if !ok || cached.Artist.ID != expected.ID {
+			t.Errorf("Expected %s to be cached as %s, got %v", expected.ID, expected.Name, cached)
+		}
+	}
+
+	if _, ok := cache.Get(defaultMarket, "Zombii"); ok {
+		t.Errorf("Expected artists cached by id not to be found by name")
+	}
+}
+
+func TestArtistCacheIsPerMarket(t *testing.T) {
+	cache := NewArtistCache(defaultArtistCacheTTL, defaultArtistCacheNegativeTTL)
+
+	zombii := &spotify.FullArtist{SimpleArtist: spotify.SimpleArtist{ID: "zombii", Name: "Zombii"}}
+	cache.Put("US", "Zombii", zombii)
+	cache.Put("GB", "Zombii", nil)
+
+	tests := []struct {
+		market string
+		name   string
+		cached bool
+		found  bool
+	}{
+		{"US", "zombii", true, true},
+		{"GB", " Zombii", true, false},
+		{"DE", "Zombii", false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.market, func(t *testing.T) {
+			entry, ok := cache.Get(test.market, test.name)
+			if ok != test.cached {
+				t.Fatalf("Expected cached %v, got %v", test.cached, ok)
+			}
+			if ok && entry.Found != test.found {
+				t.Errorf("Expected found %v, got %v", test.found, entry.Found)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"text/template"
+	"time"
+)
+
+const (
+	defaultRegionName   = "{{.Region}} weekly"
+	defaultArchiveName  = "{{.Region}} {{.WeekStart.Format \"06/01/02\"}}"
+	defaultEclecticName = "{{.Station}} {{.WeekStart.Format \"06/01/02\"}}"
+	eclecticStation     = "mbe"
+)
+
+// SpotifyConfig's Owner is the account playlists are found and created
+// under, the authenticated user when it's empty.
+type SpotifyConfig struct {
+	Owner string
+}
+
+// NamesConfig holds the text/template playlist names, see PlaylistName for
+// what they're given. An empty Yearly means no yearly collections unless a
+// region asks for one.
+type NamesConfig struct {
+	Region   string
+	Archive  string
+	Yearly   string
+	Eclectic string
+}
+
+type Config struct {
+	Spotify   SpotifyConfig
+	Names     NamesConfig
+	Email     EmailConfig
+	Notifiers []NotifierConfig
+}
+
+type PlaylistName struct {
+	Region    string
+	Station   string
+	WeekStart time.Time
+	WeekEnd   time.Time
+}
+
+func NewConfig() *Config {
+	return &Config{
+		Names: NamesConfig{
+			Region:   defaultRegionName,
+			Archive:  defaultArchiveName,
+			Eclectic: defaultEclecticName,
+		},
+		Email: NewEmailConfig(),
+	}
+}
+
+func LoadConfig(fileName string) (*Config, error) {
+	config := NewConfig()
+
+	file, err := ioutil.ReadFile(fileName)
+	if err == nil {
+		if err := json.Unmarshal(file, config); err != nil {
+			return nil, fmt.Errorf("Unable to parse %s: %v", fileName, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if err := config.Email.Override(os.Getenv); err != nil {
+		return nil, fmt.Errorf("Unable to configure email: %v", err)
+	}
+
+	// SMTP credentials used to be smtpUsername and smtpPassword in
+	// secrets.go, they're still used when nothing else sets them.
+	if config.Email.Username == "" {
+		config.Email.Username = smtpUsername
+		config.Email.Password = smtpPassword
+	}
+
+	return config, nil
+}
+
+func (c *Config) GetOwner(spotifyClient MusicService) (string, error) {
+	if c.Spotify.Owner != "" {
+		return c.Spotify.Owner, nil
+	}
+
+	user, err := spotifyClient.CurrentUser()
+	if err != nil {
+		return "", fmt.Errorf("Unable to get current user: %v", err)
+	}
+
+	return user.ID, nil
+}
+
+// NewPlaylistName is for the week, Sunday to Saturday, that t falls in.
+func NewPlaylistName(region string, station string, t time.Time) PlaylistName {
+	weekStart := GetLastSunday(t)
+	return PlaylistName{
+		Region:    region,
+		Station:   station,
+		WeekStart: weekStart,
+		WeekEnd:   weekStart.AddDate(0, 0, 6),
+	}
+}
+
+func RenderPlaylistName(text string, data PlaylistName) (string, error) {
+	tmpl, err := template.New("name").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("Unable to parse '%s': %v", text, err)
+	}
+
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return "", fmt.Errorf("Unable to render '%s': %v", text, err)
+	}
+
+	return buffer.String(), nil
+}
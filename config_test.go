@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderPlaylistName(t *testing.T) {
+	thursday := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	name := NewPlaylistName("new haven", eclecticStation, thursday)
+
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+		err      string
+	}{
+		{name: "region", text: "{{.Region}} weekly", expected: "new haven weekly"},
+		{name: "week", text: "{{.WeekStart.Format \"Jan 2\"}} - {{.WeekEnd.Format \"Jan 2\"}}", expected: "May 28 - Jun 3"},
+		{name: "bad template", text: "{{.Region weekly", err: "Unable to parse '{{.Region weekly'"},
+		{name: "unknown field", text: "{{.Venue}} weekly", err: "Unable to render '{{.Venue}} weekly'"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := RenderPlaylistName(test.text, name)
+			if test.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+					t.Errorf("Expected '%s', got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, actual)
+			}
+		})
+	}
+}
+
+// The default names are the ones that used to be hard-coded, so playlists
+// created before they could be configured are still found.
+func TestDefaultNamesMatchOldNames(t *testing.T) {
+	names := NewConfig().Names
+
+	for _, day := range []time.Time{
+		time.Date(2017, 5, 28, 0, 0, 0, 0, time.UTC),
+		time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC),
+		time.Date(2017, 12, 31, 23, 0, 0, 0, time.UTC),
+	} {
+		week := GetLastSunday(day)
+		expected := map[string]string{
+			names.Region:   "new haven weekly",
+			names.Archive:  "new haven " + week.Format("06/01/02"),
+			names.Eclectic: fmt.Sprintf("mbe %s", week.Format("06/01/02")),
+		}
+
+		for text, old := range expected {
+			station := ""
+			if text == names.Eclectic {
+				station = eclecticStation
+			}
+
+			actual, err := RenderPlaylistName(text, NewPlaylistName("new haven", station, day))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != old {
+				t.Errorf("Expected '%s' for %v, got '%s'", old, day, actual)
+			}
+
+			fake := NewFakeMusicService("jlewalle")
+			newTestPlaylist(fake, "jlewalle", "existing", old)
+			playlist, err := GetPlaylistByTitle(fake, "jlewalle", actual)
+			if err != nil || playlist == nil || playlist.ID != "existing" {
+				t.Errorf("Expected to find '%s', got %v: %v", old, playlist, err)
+			}
+		}
+	}
+}
+
+func TestUpdateRegionBadName(t *testing.T) {
+	config := NewConfig()
+	config.Names.Region = "{{.Venue}} weekly"
+
+	run := &RegionRun{
+		Client:   newTestCatalog(),
+		Resolver: NewArtistResolver(nil, nil, nil),
+		Sources:  NewEventSources(),
+		Config:   config,
+		Owner:    "jlewalle",
+		Plan:     NewPlan(),
+		Options:  Options{DryRun: true},
+		Now:      time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	_, err := run.UpdateRegion(Region{Region: "new haven"})
+	if err == nil || !strings.Contains(err.Error(), "Unable to name playlist") {
+		t.Errorf("Expected the region to fail to name its playlist, got %v", err)
+	}
+}
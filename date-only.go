@@ -34,3 +34,15 @@ var nilTime = (time.Time{}).UnixNano()
 func (do *DateOnly) IsSet() bool {
 	return do.UnixNano() != nilTime
 }
+
+func (do *DateOnly) String() string {
+	if !do.IsSet() {
+		return ""
+	}
+	return do.Time.Format(doLayout)
+}
+
+func (do *DateOnly) Set(s string) (err error) {
+	do.Time, err = time.Parse(doLayout, s)
+	return
+}
@@ -7,6 +7,8 @@ import (
 	"time"
 )
 
+var tracklistUrl = "http://tracklist-api.kcrw.com/Music/date/"
+
 type PlaylistTrack struct {
 	AffiliateLinkiPhone  string    `json:"affiliateLinkiPhone"`
 	ProgramStart         string    `json:"program_start"`
@@ -53,7 +55,7 @@ type PlaylistTracks struct {
 
 func NewPlaylistTracks(show time.Time) (*PlaylistTracks, error) {
 	search := show.Format("2006/01/02?time=15:04")
-	url := "http://tracklist-api.kcrw.com/Music/date/" + search
+	url := tracklistUrl + search
 	if false {
 		log.Printf("%s", url)
 	}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	StartTLS    = "starttls"
+	ImplicitTLS = "tls"
+	NoTLS       = "none"
+)
+
+const (
+	defaultSmtpServer = "smtp.gmail.com"
+	defaultSmtpPort   = 587
+)
+
+// EmailConfig is where reports are sent from and to. To gets every report,
+// Regions maps a region's name to more recipients who only get that region.
+// Security is StartTLS (the default), ImplicitTLS or NoTLS. The server's
+// certificate is checked against the system's roots unless rootCAs is set.
+type EmailConfig struct {
+	Server   string
+	Port     int
+	Security string
+	Username string
+	Password string
+	From     string
+	To       []string
+	Regions  map[string][]string
+	rootCAs  *x509.CertPool
+}
+
+func NewEmailConfig() EmailConfig {
+	return EmailConfig{
+		Server:   defaultSmtpServer,
+		Port:     defaultSmtpPort,
+		Security: StartTLS,
+	}
+}
+
+// Override replaces settings with any WEEKLY_SMTP_* and WEEKLY_EMAIL_*
+// environment variables, so credentials can stay out of the config file.
+func (e *EmailConfig) Override(getenv func(string) string) error {
+	if value := getenv("WEEKLY_SMTP_SERVER"); value != "" {
+		e.Server = value
+	}
+	if value := getenv("WEEKLY_SMTP_PORT"); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("Bad WEEKLY_SMTP_PORT '%s': %v", value, err)
+		}
+		e.Port = port
+	}
+	if value := getenv("WEEKLY_SMTP_SECURITY"); value != "" {
+		e.Security = value
+	}
+	if value := getenv("WEEKLY_SMTP_USERNAME"); value != "" {
+		e.Username = value
+	}
+	if value := getenv("WEEKLY_SMTP_PASSWORD"); value != "" {
+		e.Password = value
+	}
+	if value := getenv("WEEKLY_EMAIL_FROM"); value != "" {
+		e.From = value
+	}
+	if value := getenv("WEEKLY_EMAIL_TO"); value != "" {
+		e.To = SplitAddresses(value)
+	}
+	return e.Validate()
+}
+
+func (e *EmailConfig) Validate() error {
+	switch e.Security {
+	case StartTLS, ImplicitTLS, NoTLS:
+	default:
+		return fmt.Errorf("Unknown email security '%s', expected %s, %s or %s", e.Security, StartTLS, ImplicitTLS, NoTLS)
+	}
+
+	addresses := append([]string{}, e.To...)
+	if e.From != "" {
+		addresses = append(addresses, e.From)
+	}
+	for _, recipients := range e.Regions {
+		addresses = append(addresses, recipients...)
+	}
+	for _, address := range addresses {
+		if _, err := mail.ParseAddress(address); err != nil {
+			return fmt.Errorf("Bad email address '%s': %v", address, err)
+		}
+	}
+
+	return nil
+}
+
+func (e *EmailConfig) GetFrom() string {
+	if e.From != "" {
+		return e.From
+	}
+	return e.Username
+}
+
+func SplitAddresses(value string) (addresses []string) {
+	for _, address := range strings.Split(value, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return
+}
+
+// Send delivers msg to every recipient in one SMTP session.
+func (e *EmailConfig) Send(to []string, msg []byte) error {
+	address := net.JoinHostPort(e.Server, strconv.Itoa(e.Port))
+	tlsConfig := &tls.Config{ServerName: e.Server, RootCAs: e.rootCAs}
+
+	var conn net.Conn
+	var err error
+	if e.Security == ImplicitTLS {
+		conn, err = tls.Dial("tcp", address, tlsConfig)
+	} else {
+		conn, err = net.Dial("tcp", address)
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to connect to %s: %v", address, err)
+	}
+
+	client, err := smtp.NewClient(conn, e.Server)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if e.Security == StartTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("Unable to STARTTLS: %v", err)
+		}
+	}
+
+	if e.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", e.Username, e.Password, e.Server)); err != nil {
+			return fmt.Errorf("Unable to authenticate: %v", err)
+		}
+	}
+
+	if err := client.Mail(BareAddress(e.GetFrom())); err != nil {
+		return err
+	}
+	for _, recipient := range to {
+		if err := client.Rcpt(BareAddress(recipient)); err != nil {
+			return fmt.Errorf("Unable to send to %s: %v", recipient, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// BareAddress strips the name from "Name <user@example.com>", which SMTP
+// commands don't allow.
+func BareAddress(address string) string {
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		return address
+	}
+	return parsed.Address
+}
+
+// NewAlternativeMessage is a multipart/alternative message with a plain text
+// part for mail clients that don't show HTML, followed by the HTML. Both are
+// quoted-printable so long lines survive.
+func NewAlternativeMessage(from string, to []string, subject string, text string, html string) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	parts := []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=\"UTF-8\"", text},
+		{"text/html; charset=\"UTF-8\"", html},
+	}
+	for _, part := range parts {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		w, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: %s\r\n", NewMessageId(from))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=\"%s\"\r\n\r\n", writer.Boundary())
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
+
+func NewMessageId(from string) string {
+	domain := "localhost"
+	address := BareAddress(from)
+	if at := strings.LastIndex(address, "@"); at >= 0 {
+		domain = address[at+1:]
+	}
+
+	random := make([]byte, 12)
+	rand.Read(random)
+
+	return fmt.Sprintf("<%d.%x@%s>", time.Now().UnixNano(), random, domain)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeSmtpSession is what a client told fakeSmtpServer.
+type fakeSmtpSession struct {
+	tls  bool
+	auth string
+	from string
+	to   []string
+	data []byte
+}
+
+// fakeSmtpServer accepts a single session on listener, switching to TLS
+// with certificate when the client asks for STARTTLS.
+func fakeSmtpServer(t *testing.T, listener net.Listener, certificate *tls.Certificate, implicitTLS bool) <-chan *fakeSmtpSession {
+	sessions := make(chan *fakeSmtpSession, 1)
+
+	go func() {
+		defer close(sessions)
+
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		session := &fakeSmtpSession{tls: implicitTLS}
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 localhost ESMTP")
+
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				t.Errorf("Unable to read command: %v", err)
+				return
+			}
+
+			command := strings.ToUpper(strings.Fields(line)[0])
+			switch command {
+			case "EHLO", "HELO":
+				text.PrintfLine("250-localhost")
+				if !session.tls {
+					text.PrintfLine("250-STARTTLS")
+				}
+				text.PrintfLine("250 AUTH PLAIN")
+			case "STARTTLS":
+				text.PrintfLine("220 Ready to start TLS")
+				tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{*certificate}})
+				if err := tlsConn.Handshake(); err != nil {
+					t.Errorf("Unable to STARTTLS: %v", err)
+					return
+				}
+				conn = tlsConn
+				text = textproto.NewConn(tlsConn)
+				session.tls = true
+			case "AUTH":
+				credentials, _ := base64.StdEncoding.DecodeString(strings.Fields(line)[2])
+				session.auth = string(credentials)
+				text.PrintfLine("235 Authenticated")
+			case "MAIL":
+				session.from = line[len("MAIL FROM:"):]
+				text.PrintfLine("250 OK")
+			case "RCPT":
+				session.to = append(session.to, line[len("RCPT TO:"):])
+				text.PrintfLine("250 OK")
+			case "DATA":
+				text.PrintfLine("354 Go ahead")
+				session.data, err = text.ReadDotBytes()
+				if err != nil {
+					t.Errorf("Unable to read message: %v", err)
+					return
+				}
+				text.PrintfLine("250 Queued")
+			case "QUIT":
+				text.PrintfLine("221 Bye")
+				sessions <- session
+				return
+			default:
+				text.PrintfLine("502 Unknown command")
+			}
+		}
+	}()
+
+	return sessions
+}
+
+func TestEmailConfigSend(t *testing.T) {
+	// httptest's certificate is good for 127.0.0.1.
+	https := httptest.NewTLSServer(nil)
+	certificate := https.TLS.Certificates[0]
+	roots := x509.NewCertPool()
+	roots.AddCert(https.Certificate())
+	https.Close()
+
+	text := "Cafe Nine: " + strings.Repeat("RYXNO, Dr. Beardface and the Spacemen, Zombii ", 4)
+	html := "<p>" + text + "</p>"
+
+	tests := []struct {
+		security string
+		username string
+		to       []string
+	}{
+		{NoTLS, "", []string{"jlewalle@gmail.com"}},
+		{StartTLS, "jcl.automated@gmail.com", []string{"Jacob <jlewalle@gmail.com>", "someone@example.com"}},
+		{ImplicitTLS, "jcl.automated@gmail.com", []string{"jlewalle@gmail.com", "someone@example.com", "else@example.com"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.security, func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer listener.Close()
+			if test.security == ImplicitTLS {
+				listener = tls.NewListener(listener, &tls.Config{Certificates: []tls.Certificate{certificate}})
+			}
+
+			sessions := fakeSmtpServer(t, listener, &certificate, test.security == ImplicitTLS)
+
+			email := EmailConfig{
+				Server:   "127.0.0.1",
+				Port:     listener.Addr().(*net.TCPAddr).Port,
+				Security: test.security,
+				Username: test.username,
+				Password: "hunter2",
+				From:     "Weekly Playlist <weekly@example.com>",
+				To:       test.to,
+				rootCAs:  roots,
+			}
+
+			msg, err := NewAlternativeMessage(email.GetFrom(), email.To, "weekly-playlist: Report for Jun 1", text, html)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := email.Send(email.To, msg); err != nil {
+				t.Fatal(err)
+			}
+
+			session := <-sessions
+			if session == nil {
+				t.Fatal("Expected a session")
+			}
+
+			if session.tls != (test.security != NoTLS) {
+				t.Errorf("Expected TLS %v, got %v", test.security != NoTLS, session.tls)
+			}
+			if expected := "\x00" + test.username + "\x00hunter2"; test.username != "" && session.auth != expected {
+				t.Errorf("Expected to authenticate as '%s', got '%s'", test.username, session.auth)
+			}
+			if test.username == "" && session.auth != "" {
+				t.Errorf("Expected no authentication")
+			}
+			if session.from != "<weekly@example.com>" {
+				t.Errorf("Expected to send from <weekly@example.com>, got %s", session.from)
+			}
+			expected := make([]string, 0)
+			for _, to := range test.to {
+				expected = append(expected, "<"+BareAddress(to)+">")
+			}
+			if !reflect.DeepEqual(session.to, expected) {
+				t.Errorf("Expected to send to %v, got %v", expected, session.to)
+			}
+
+			checkAlternativeMessage(t, session.data, text, html)
+		})
+	}
+}
+
+func checkAlternativeMessage(t *testing.T, data []byte, text string, html string) {
+	msg, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(string(data))))
+	if err != nil {
+		t.Fatalf("Unable to read message: %v", err)
+	}
+
+	if subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); err != nil || subject != "weekly-playlist: Report for Jun 1" {
+		t.Errorf("Unexpected subject '%s': %v", subject, err)
+	}
+	if msg.Header.Get("Message-ID") == "" {
+		t.Errorf("Expected a Message-ID")
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Expected multipart/alternative, got '%s': %v", mediaType, err)
+	}
+
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for _, expected := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain", text},
+		{"text/html", html},
+	} {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("Expected a %s part: %v", expected.contentType, err)
+		}
+		if contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type")); contentType != expected.contentType {
+			t.Errorf("Expected %s, got %s", expected.contentType, contentType)
+		}
+		content, err := ioutil.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != expected.content {
+			t.Errorf("Expected '%s', got '%s'", expected.content, content)
+		}
+	}
+
+	if _, err := reader.NextPart(); err == nil {
+		t.Errorf("Expected only two parts")
+	}
+}
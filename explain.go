@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/zmb3/spotify"
+)
+
+const (
+	MatchedOutcome   = "matched"
+	NoMatchOutcome   = "no match"
+	SkippedOutcome   = "skipped"
+	PrunedOutcome    = "pruned"
+	PerformerOutcome = "not searched"
+	ErrorOutcome     = "error"
+)
+
+// GuessExplanation is a guess and what became of it. Reason says why a guess
+// wasn't matched or, for pruned guesses, why it was never searched for.
+// Cached outcomes came from the artist cache instead of Spotify, searches that
+// failed are errors rather than no match.
+type GuessExplanation struct {
+	Step        string
+	Description string
+	Name        string
+	Role        string `json:",omitempty"`
+	Outcome     string
+	Cached      bool       `json:",omitempty"`
+	ArtistId    spotify.ID `json:",omitempty"`
+	Artist      string     `json:",omitempty"`
+	Reason      string     `json:",omitempty"`
+	Children    []*GuessExplanation
+}
+
+type Explanation struct {
+	Title   string
+	Venue   string `json:",omitempty"`
+	Region  string `json:",omitempty"`
+	Artists []HistoryArtist
+	Guesses *GuessExplanation
+}
+
+// NewGuessExplanation walks the guess tree alongside what the resolver
+// traced. Guesses without a trace were pruned, pruned explains why.
+func NewGuessExplanation(guess *ArtistGuess, trace map[*ArtistGuess]*GuessTrace, pruned string) *GuessExplanation {
+	explanation := &GuessExplanation{
+		Step:        guess.Step,
+		Description: StepDescriptions[guess.Step],
+		Name:        guess.Name,
+		Role:        guess.Role,
+		Children:    make([]*GuessExplanation, 0),
+	}
+
+	childrenPruned := pruned
+	traced, ok := trace[guess]
+	if pruned != "" {
+		explanation.Outcome = PrunedOutcome
+		explanation.Reason = pruned
+	} else if !ok && guess.Step == PerformersStep {
+		explanation.Outcome = PerformerOutcome
+		explanation.Reason = "performers are searched for instead of the title"
+	} else if !ok && guess.Role == PresenterRole {
+		explanation.Outcome = PerformerOutcome
+		explanation.Reason = "presenters aren't played"
+		childrenPruned = explanation.Reason
+	} else if !ok {
+		explanation.Outcome = PrunedOutcome
+		explanation.Reason = "never reached"
+	} else {
+		explanation.Cached = traced.Cached
+		if traced.Skipped {
+			explanation.Outcome = SkippedOutcome
+			explanation.Reason = "an override skips it"
+			childrenPruned = fmt.Sprintf("'%s' was skipped", guess.Name)
+		} else if traced.Artist != nil {
+			explanation.Outcome = MatchedOutcome
+			explanation.ArtistId = traced.Artist.ID
+			explanation.Artist = traced.Artist.Name
+			childrenPruned = fmt.Sprintf("'%s' already matched %s", guess.Name, traced.Artist.Name)
+		} else if len(traced.Errors) > 0 {
+			// A failed search says nothing about whether the name exists.
+			explanation.Outcome = ErrorOutcome
+			explanation.Reason = strings.Join(traced.Errors, ", ")
+		} else {
+			explanation.Outcome = NoMatchOutcome
+			if traced.Ambiguity != nil {
+				explanation.Reason = fmt.Sprintf("ambiguous, %s: %s", traced.Ambiguity.Reason, strings.Join(traced.Ambiguity.Candidates, ", "))
+			} else if traced.NearMiss != nil {
+				explanation.Reason = fmt.Sprintf("near miss, %s (%s) scored %.2f", traced.NearMiss.Candidate, traced.NearMiss.ArtistId, traced.NearMiss.Score)
+			}
+		}
+	}
+
+	for _, child := range guess.Children {
+		explanation.Children = append(explanation.Children, NewGuessExplanation(child, trace, childrenPruned))
+	}
+
+	return explanation
+}
+
+// Explain resolves title the way a run would, at venue and in region when
+// they're given, keeping track of every guess along the way.
+func Explain(spotifyClient MusicService, resolver *ArtistResolver, region *Region, venue string, title string) *Explanation {
+	res := NewResolution(region, nil)
+	res.VenueKey = venue
+	res.Trace = make(map[*ArtistGuess]*GuessTrace)
+
+	event := Event{Name: title, Venue: venue, Artists: GuessArtistsForEvent(title)}
+	artists := resolver.GetSpotifyArtists(spotifyClient, res, event)
+
+	explanation := &Explanation{
+		Title:   title,
+		Venue:   venue,
+		Artists: make([]HistoryArtist, 0),
+		Guesses: NewGuessExplanation(event.Artists, res.Trace, ""),
+	}
+	if region != nil {
+		explanation.Region = region.Region
+	}
+
+	for _, artist := range artists {
+		explanation.Artists = append(explanation.Artists, HistoryArtist{
+			Guess:    artist.Guess,
+			Path:     artist.Path,
+			Role:     artist.Role,
+			ArtistId: artist.Artist.ID,
+			Name:     artist.Artist.Name,
+		})
+	}
+
+	return explanation
+}
+
+func PrintGuessExplanation(w io.Writer, explanation *GuessExplanation, depth int) {
+	fmt.Fprintf(w, "[%-4s]%s%s", explanation.Step, strings.Repeat("  ", depth+1), explanation.Name)
+	if explanation.Role != "" {
+		fmt.Fprintf(w, " (%s)", explanation.Role)
+	}
+	fmt.Fprintf(w, "\n%s%s  %s", strings.Repeat(" ", 6), strings.Repeat("  ", depth+1), explanation.Outcome)
+	if explanation.Cached {
+		fmt.Fprintf(w, " (cached)")
+	}
+	if explanation.Artist != "" {
+		fmt.Fprintf(w, " %s (%s)", explanation.Artist, explanation.ArtistId)
+	}
+	if explanation.Reason != "" {
+		fmt.Fprintf(w, ": %s", explanation.Reason)
+	}
+	fmt.Fprintf(w, "\n")
+
+	for _, child := range explanation.Children {
+		PrintGuessExplanation(w, child, depth+1)
+	}
+}
+
+func PrintExplanation(w io.Writer, explanation *Explanation) {
+	fmt.Fprintf(w, "'%s'\n\n", explanation.Title)
+
+	fmt.Fprintf(w, "Steps:\n")
+	steps := make(map[string]bool)
+	var listSteps func(*GuessExplanation)
+	listSteps = func(e *GuessExplanation) {
+		if e.Step != "" && !steps[e.Step] {
+			steps[e.Step] = true
+			fmt.Fprintf(w, "   %-4s %s\n", e.Step, e.Description)
+		}
+		for _, child := range e.Children {
+			listSteps(child)
+		}
+	}
+	listSteps(explanation.Guesses)
+	fmt.Fprintf(w, "\n")
+
+	PrintGuessExplanation(w, explanation.Guesses, 0)
+	fmt.Fprintf(w, "\n")
+
+	if len(explanation.Artists) == 0 {
+		fmt.Fprintf(w, "NO ARTISTS\n")
+	}
+	for _, artist := range explanation.Artists {
+		fmt.Fprintf(w, "%s (%s) %s via %s\n", artist.Name, artist.ArtistId, artist.Role, strings.Join(artist.Path, "/"))
+	}
+}
+
+// ExplainCommand prints how an event's title is guessed at and resolved.
+func ExplainCommand(spotifyClient MusicService, resolver *ArtistResolver, regionsFile string, args []string) error {
+	flags := flag.NewFlagSet("explain", flag.ExitOnError)
+	regionName := flags.String("region", "", "resolve using this region's market and genres")
+	venue := flags.String("venue", "", "resolve at this venue (its Id or name), for overrides and the venue's genres")
+	noCache := flags.Bool("no-cache", false, "search Spotify instead of using the artist cache")
+	asJson := flags.Bool("json", false, "print as a line of json")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("Usage: explain [-region name] [-venue name] [-no-cache] [-json] \"<event title>\"")
+	}
+
+	if *noCache {
+		resolver = NewArtistResolver(nil, resolver.overrides, resolver.matcher)
+	}
+
+	var region *Region
+	if *regionName != "" {
+		regions := LoadRegions(regionsFile)
+		for i := range regions {
+			if strings.EqualFold(regions[i].Region, *regionName) || regions[i].Id == *regionName {
+				region = &regions[i]
+			}
+		}
+		if region == nil {
+			return fmt.Errorf("No region '%s'", *regionName)
+		}
+	}
+
+	explanation := Explain(spotifyClient, resolver, region, *venue, flags.Arg(0))
+
+	if *asJson {
+		return json.NewEncoder(os.Stdout).Encode(explanation)
+	}
+
+	PrintExplanation(os.Stdout, explanation)
+
+	return nil
+}
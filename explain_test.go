@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExplainCachedAndErrors(t *testing.T) {
+	fake := newTestCatalog()
+	resolver := NewArtistResolver(nil, nil, nil)
+
+	explanation := Explain(fake, resolver, nil, "", "Zombii")
+	if guess := explanation.Guesses; guess.Outcome != MatchedOutcome || guess.Cached {
+		t.Errorf("Expected a match from Spotify, got %s (cached %v)", guess.Outcome, guess.Cached)
+	}
+
+	explanation = Explain(fake, resolver, nil, "", "Zombii")
+	if guess := explanation.Guesses; guess.Outcome != MatchedOutcome || !guess.Cached {
+		t.Errorf("Expected a cached match, got %s (cached %v)", guess.Outcome, guess.Cached)
+	}
+
+	fake.RateLimitEvery = 1
+	explanation = Explain(fake, NewArtistResolver(nil, nil, nil), nil, "", "Zombii")
+	if guess := explanation.Guesses; guess.Outcome != ErrorOutcome || !strings.Contains(guess.Reason, "rate limit") {
+		t.Errorf("Expected the failed search to be an error, got %s: %s", guess.Outcome, guess.Reason)
+	}
+	if len(explanation.Artists) != 0 {
+		t.Errorf("Expected no artists, got %v", explanation.Artists)
+	}
+}
+
+func TestJsonCommandsKeepStdoutForJson(t *testing.T) {
+	stdout, stderr := os.Stdout, os.Stderr
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer null.Close()
+	os.Stdout, os.Stderr = w, null
+	defer func() {
+		os.Stdout, os.Stderr = stdout, stderr
+		log.SetOutput(os.Stderr)
+	}()
+
+	history := filepath.Join(t.TempDir(), "history.jsonl")
+	now := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		if err := AppendHistory(history, NewHistoryRecord(NewRunReport(now, false), now)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, command := range []string{"explain", "history"} {
+		SetupLog(ioutil.Discard, command)
+		log.Printf("Loaded 10 cached artists")
+	}
+
+	SetupLog(ioutil.Discard, "explain")
+	if err := ExplainCommand(newTestCatalog(), NewArtistResolver(nil, nil, nil), "", []string{"-json", "Zombii with RYXNO"}); err != nil {
+		t.Fatal(err)
+	}
+	SetupLog(ioutil.Discard, "history")
+	if err := HistoryCommand(history, []string{"-json", "-weeks", "0"}, now); err != nil {
+		t.Fatal(err)
+	}
+
+	w.Close()
+	os.Stdout = stdout
+	output, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected an explanation and 2 runs, got %q", lines)
+	}
+	for _, line := range lines {
+		var value map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &value); err != nil {
+			t.Errorf("Expected json, got '%s': %v", line, err)
+		}
+	}
+}
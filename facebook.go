@@ -78,6 +78,10 @@ func handleFacebookCallback(w http.ResponseWriter, r *http.Request) {
 	os.Exit(0)
 }
 
+// Events come back newest first, so paging stops at the first event before the
+// window. This only bounds runaway paging through pages without dates.
+const facebookMaximumPages = 100
+
 type FacebookEvent struct {
 	Id              string `facebook:",required"`
 	StartTime       string
@@ -131,7 +135,7 @@ func GetVenueInformation(session *fb.Session, venueId string) (venueInfo VenueIn
 	return
 }
 
-func GetUpcomingFacebookEvents(session *fb.Session, venueId string) (events []FacebookEvent, err error) {
+func GetUpcomingFacebookEvents(session *fb.Session, venueId string, window Window) (events []FacebookEvent, err error) {
 	res, err := session.Get("/"+venueId+"/events", nil)
 	if err != nil {
 		return
@@ -141,13 +145,13 @@ func GetUpcomingFacebookEvents(session *fb.Session, venueId string) (events []Fa
 	var page FacebookEvents
 	paging, _ := res.Paging(session)
 
-	for numberOfPages := 0; numberOfPages < 10; numberOfPages++ {
+	for numberOfPages := 0; numberOfPages < facebookMaximumPages; numberOfPages++ {
 		paging.Decode(&page)
 		done := false
 		for _, event := range page.Events {
 			event.StartTimeParsed, err = time.Parse("2006-01-02T15:04:05-0700", event.StartTime)
 
-			if event.StartTimeParsed.Before(time.Now()) {
+			if event.StartTimeParsed.Before(window.From) {
 				done = true
 			}
 
@@ -158,16 +162,60 @@ func GetUpcomingFacebookEvents(session *fb.Session, venueId string) (events []Fa
 			break
 		}
 
-		paging.Next()
+		noMore, err := paging.Next()
+		if noMore || err != nil {
+			break
+		}
 	}
 
-	aWeekFromNow := time.Now().AddDate(0, 0, 7)
 	events = unfiltered[:0]
 	for _, event := range unfiltered {
-		if event.StartTimeParsed.After(time.Now()) && event.StartTimeParsed.Before(aWeekFromNow) {
+		if window.Contains(event.StartTimeParsed) {
 			events = append(events, event)
 		}
 	}
 
 	return
 }
+
+type FacebookEventSource struct {
+	session   *fb.Session
+	venue     Venue
+	venueName string
+}
+
+func (s *FacebookEventSource) GetVenue() Venue {
+	return s.venue
+}
+
+func (s *FacebookEventSource) GetVenueName() (string, error) {
+	if s.venueName == "" {
+		s.venueName = GetVenueDisplayName(s.venue)
+		if s.venue.Name == "" {
+			venueInfo, err := GetVenueInformation(s.session, s.venue.Id)
+			if err != nil {
+				return s.venueName, err
+			}
+			if venueInfo.Name != "" {
+				s.venueName = venueInfo.Name
+			}
+		}
+	}
+	return s.venueName, nil
+}
+
+func (s *FacebookEventSource) GetUpcomingEvents(window Window) (events []Event, err error) {
+	venueName, _ := s.GetVenueName()
+
+	facebookEvents, err := GetUpcomingFacebookEvents(s.session, s.venue.Id, window)
+	for _, event := range facebookEvents {
+		events = append(events, Event{
+			Name:      event.Name,
+			StartTime: event.StartTimeParsed,
+			Venue:     venueName,
+			TicketUrl: "https://www.facebook.com/events/" + event.Id,
+		})
+	}
+
+	return
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zmb3/spotify"
+)
+
+type FakePlaylist struct {
+	Playlist    spotify.SimplePlaylist
+	Description string
+	Tracks      []spotify.PlaylistTrack
+}
+
+// FakeMusicService keeps a catalog and playlists in memory, for running
+// offline with --offline. Every RateLimitEvery'th call fails with the same
+// 429 Spotify returns when throttling. It's safe for concurrent use.
+type FakeMusicService struct {
+	User           string
+	Artists        []spotify.FullArtist
+	TopTracks      map[spotify.ID][]spotify.FullTrack
+	Albums         map[spotify.ID][]spotify.SimpleAlbum
+	AlbumTracks    map[spotify.ID][]spotify.SimpleTrack
+	Tracks         []spotify.FullTrack
+	Playlists      []*FakePlaylist
+	RateLimitEvery int
+	mutex          sync.Mutex
+	calls          int
+	created        int
+}
+
+func NewFakeMusicService(user string) *FakeMusicService {
+	return &FakeMusicService{
+		User:        user,
+		TopTracks:   make(map[spotify.ID][]spotify.FullTrack),
+		Albums:      make(map[spotify.ID][]spotify.SimpleAlbum),
+		AlbumTracks: make(map[spotify.ID][]spotify.SimpleTrack),
+	}
+}
+
+func LoadFakeMusicService(fileName string) (*FakeMusicService, error) {
+	file, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	fake := NewFakeMusicService("")
+	if err := json.Unmarshal(file, fake); err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %v", fileName, err)
+	}
+
+	return fake, nil
+}
+
+func (f *FakeMusicService) call() error {
+	f.calls++
+	if f.RateLimitEvery > 0 && f.calls%f.RateLimitEvery == 0 {
+		return spotify.Error{Status: http.StatusTooManyRequests, Message: "API rate limit exceeded"}
+	}
+	return nil
+}
+
+func FakePage(total int, opt *spotify.Options, defaultLimit int) (offset int, end int) {
+	limit := defaultLimit
+	if opt != nil && opt.Limit != nil {
+		limit = *opt.Limit
+	}
+	if opt != nil && opt.Offset != nil {
+		offset = *opt.Offset
+	}
+	if offset > total {
+		offset = total
+	}
+	return offset, min(offset+limit, total)
+}
+
+func FakeMatches(query string, values ...string) bool {
+	haystack := strings.ToLower(strings.Join(values, " "))
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(haystack, word) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *FakeMusicService) FindTrack(id spotify.ID) (*spotify.FullTrack, bool) {
+	for i := range f.Tracks {
+		if f.Tracks[i].ID == id {
+			return &f.Tracks[i], true
+		}
+	}
+	for _, tracks := range f.TopTracks {
+		for i := range tracks {
+			if tracks[i].ID == id {
+				return &tracks[i], true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (f *FakeMusicService) FindPlaylist(id spotify.ID) (*FakePlaylist, error) {
+	for _, pl := range f.Playlists {
+		if pl.Playlist.ID == id {
+			return pl, nil
+		}
+	}
+	return nil, spotify.Error{Status: http.StatusNotFound, Message: "Not found."}
+}
+
+func (f *FakeMusicService) CurrentUser() (*spotify.PrivateUser, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.call(); err != nil {
+		return nil, err
+	}
+	user := &spotify.PrivateUser{}
+	user.ID = f.User
+	return user, nil
+}
+
+func (f *FakeMusicService) SearchOpt(query string, t spotify.SearchType, opt *spotify.Options) (*spotify.SearchResult, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.call(); err != nil {
+		return nil, err
+	}
+
+	result := &spotify.SearchResult{}
+	if t&spotify.SearchTypeArtist != 0 {
+		result.Artists = &spotify.FullArtistPage{Artists: make([]spotify.FullArtist, 0)}
+		for _, artist := range f.Artists {
+			if FakeMatches(query, artist.Name) {
+				result.Artists.Artists = append(result.Artists.Artists, artist)
+			}
+		}
+	}
+	if t&spotify.SearchTypeTrack != 0 {
+		result.Tracks = &spotify.FullTrackPage{Tracks: make([]spotify.FullTrack, 0)}
+		for _, track := range f.Tracks {
+			names := []string{track.Name}
+			for _, artist := range track.Artists {
+				names = append(names, artist.Name)
+			}
+			if FakeMatches(query, names...) {
+				result.Tracks.Tracks = append(result.Tracks.Tracks, track)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func (f *FakeMusicService) GetArtist(id spotify.ID) (*spotify.FullArtist, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.call(); err != nil {
+		return nil, err
+	}
+	for i := range f.Artists {
+		if f.Artists[i].ID == id {
+			return &f.Artists[i], nil
+		}
+	}
+	return nil, spotify.Error{Status: http.StatusNotFound, Message: "non existing id"}
+}
+
+func (f *FakeMusicService) GetArtistsTopTracks(artistID spotify.ID, country string) ([]spotify.FullTrack, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.call(); err != nil {
+		return nil, err
+	}
+	// Like Spotify, tracks fetched for a market say if they're playable in
+	// it instead of listing where they are.
+	tracks := make([]spotify.FullTrack, 0)
+	for _, track := range f.TopTracks[artistID] {
+		if len(track.AvailableMarkets) > 0 {
+			playable := IsPlayableIn(track, country)
+			track.IsPlayable = &playable
+			track.AvailableMarkets = nil
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks, nil
+}
+
+func (f *FakeMusicService) GetArtistAlbumsOpt(artistID spotify.ID, options *spotify.Options, t *spotify.AlbumType) (*spotify.SimpleAlbumPage, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.call(); err != nil {
+		return nil, err
+	}
+	albums := f.Albums[artistID]
+	offset, end := FakePage(len(albums), options, 20)
+	page := &spotify.SimpleAlbumPage{Albums: albums[offset:end]}
+	page.Offset = offset
+	page.Total = len(albums)
+	return page, nil
+}
+
+func (f *FakeMusicService) GetAlbumTracksOpt(id spotify.ID, limit, offset int) (*spotify.SimpleTrackPage, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.call(); err != nil {
+		return nil, err
+	}
+	tracks := f.AlbumTracks[id]
+	start, end := FakePage(len(tracks), &spotify.Options{Limit: &limit, Offset: &offset}, limit)
+	page := &spotify.SimpleTrackPage{Tracks: tracks[start:end]}
+	page.Offset = start
+	page.Total = len(tracks)
+	return page, nil
+}
+
+func (f *FakeMusicService) GetPlaylistsForUserOpt(userID string, opt *spotify.Options) (*spotify.SimplePlaylistPage, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.call(); err != nil {
+		return nil, err
+	}
+	playlists := make([]spotify.SimplePlaylist, 0)
+	for _, pl := range f.Playlists {
+		if pl.Playlist.Owner.ID == userID {
+			playlists = append(playlists, pl.Playlist)
+		}
+	}
+	offset, end := FakePage(len(playlists), opt, 20)
+	page := &spotify.SimplePlaylistPage{Playlists: playlists[offset:end]}
+	page.Offset = offset
+	page.Total = len(playlists)
+	return page, nil
+}
+
+func (f *FakeMusicService) CreatePlaylistForUser(userID, playlistName, description string, public bool) (*spotify.FullPlaylist, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.call(); err != nil {
+		return nil, err
+	}
+	f.created++
+	pl := &FakePlaylist{Description: description, Tracks: make([]spotify.PlaylistTrack, 0)}
+	pl.Playlist.ID = spotify.ID(fmt.Sprintf("fake%04d", f.created))
+	pl.Playlist.Name = playlistName
+	pl.Playlist.Owner.ID = userID
+	pl.Playlist.IsPublic = public
+	f.Playlists = append(f.Playlists, pl)
+
+	return &spotify.FullPlaylist{SimplePlaylist: pl.Playlist, Description: description}, nil
+}
+
+func (f *FakeMusicService) GetPlaylistTracksOpt(playlistID spotify.ID, opt *spotify.Options, fields string) (*spotify.PlaylistTrackPage, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.call(); err != nil {
+		return nil, err
+	}
+	pl, err := f.FindPlaylist(playlistID)
+	if err != nil {
+		return nil, err
+	}
+	offset, end := FakePage(len(pl.Tracks), opt, 100)
+	page := &spotify.PlaylistTrackPage{Tracks: pl.Tracks[offset:end]}
+	page.Offset = offset
+	page.Total = len(pl.Tracks)
+	return page, nil
+}
+
+func (f *FakeMusicService) AddTracksToPlaylist(playlistID spotify.ID, trackIDs ...spotify.ID) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.call(); err != nil {
+		return "", err
+	}
+	if len(trackIDs) > 100 {
+		return "", spotify.Error{Status: http.StatusBadRequest, Message: "You can add a maximum of 100 tracks per request."}
+	}
+	pl, err := f.FindPlaylist(playlistID)
+	if err != nil {
+		return "", err
+	}
+	for _, id := range trackIDs {
+		track, ok := f.FindTrack(id)
+		if !ok {
+			return "", spotify.Error{Status: http.StatusBadRequest, Message: "Invalid track uri: spotify:track:" + string(id)}
+		}
+		pl.Tracks = append(pl.Tracks, spotify.PlaylistTrack{AddedAt: time.Now().UTC().Format(time.RFC3339), Track: *track})
+	}
+	pl.Playlist.Tracks.Total = uint(len(pl.Tracks))
+	return fmt.Sprintf("snapshot%d", f.calls), nil
+}
+
+func (f *FakeMusicService) RemoveTracksFromPlaylist(playlistID spotify.ID, trackIDs ...spotify.ID) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.call(); err != nil {
+		return "", err
+	}
+	if len(trackIDs) > 100 {
+		return "", spotify.Error{Status: http.StatusBadRequest, Message: "You can remove a maximum of 100 tracks per request."}
+	}
+	pl, err := f.FindPlaylist(playlistID)
+	if err != nil {
+		return "", err
+	}
+	removing := NewTracksSet(trackIDs)
+	kept := make([]spotify.PlaylistTrack, 0)
+	for _, track := range pl.Tracks {
+		if !removing.Contains(track.Track.ID) {
+			kept = append(kept, track)
+		}
+	}
+	pl.Tracks = kept
+	pl.Playlist.Tracks.Total = uint(len(pl.Tracks))
+	return fmt.Sprintf("snapshot%d", f.calls), nil
+}
+
+func (f *FakeMusicService) ReorderPlaylistTracks(playlistID spotify.ID, opt spotify.PlaylistReorderOptions) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.call(); err != nil {
+		return "", err
+	}
+	pl, err := f.FindPlaylist(playlistID)
+	if err != nil {
+		return "", err
+	}
+
+	length := opt.RangeLength
+	if length == 0 {
+		length = 1
+	}
+	if opt.RangeStart < 0 || opt.RangeStart+length > len(pl.Tracks) || opt.InsertBefore < 0 || opt.InsertBefore > len(pl.Tracks) {
+		return "", spotify.Error{Status: http.StatusBadRequest, Message: "Index out of bounds."}
+	}
+
+	moving := make([]spotify.PlaylistTrack, length)
+	copy(moving, pl.Tracks[opt.RangeStart:opt.RangeStart+length])
+	kept := make([]spotify.PlaylistTrack, 0, len(pl.Tracks))
+	kept = append(kept, pl.Tracks[:opt.RangeStart]...)
+	kept = append(kept, pl.Tracks[opt.RangeStart+length:]...)
+
+	insert := opt.InsertBefore
+	if insert > opt.RangeStart {
+		insert -= length
+	}
+
+	reordered := make([]spotify.PlaylistTrack, 0, len(pl.Tracks))
+	reordered = append(reordered, kept[:insert]...)
+	reordered = append(reordered, moving...)
+	reordered = append(reordered, kept[insert:]...)
+	pl.Tracks = reordered
+
+	return fmt.Sprintf("snapshot%d", f.calls), nil
+}
+
+func (f *FakeMusicService) UnfollowPlaylist(owner, playlist spotify.ID) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.call(); err != nil {
+		return err
+	}
+	for i, pl := range f.Playlists {
+		if pl.Playlist.ID == playlist {
+			f.Playlists = append(f.Playlists[:i], f.Playlists[i+1:]...)
+			return nil
+		}
+	}
+	return spotify.Error{Status: http.StatusNotFound, Message: "Not found."}
+}
+
+func (f *FakeMusicService) ChangePlaylistDescription(playlistID spotify.ID, newDescription string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.call(); err != nil {
+		return err
+	}
+	pl, err := f.FindPlaylist(playlistID)
+	if err != nil {
+		return err
+	}
+	pl.Description = newDescription
+	return nil
+}
+
+func (f *FakeMusicService) ChangePlaylistAccess(playlistID spotify.ID, public bool) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.call(); err != nil {
+		return err
+	}
+	pl, err := f.FindPlaylist(playlistID)
+	if err != nil {
+		return err
+	}
+	pl.Playlist.IsPublic = public
+	return nil
+}
@@ -5,12 +5,81 @@ import (
 	"strings"
 )
 
+const PerformersStep = "EP"
+
+// StepDescriptions say what produced a guess with each Step.
+var StepDescriptions = map[string]string{
+	"":             "the event's title",
+	"C":            "the title without sold out notices, cities, parentheses and anything after |",
+	"I":            "split on with, w/, feat, presents, special guest, + more, commas, | and :",
+	"SAP":          "& replaced with and",
+	"SMA":          "split on commas, &, and, y, + and :",
+	"RV":           "\" at <venue>\" removed, then split like I",
+	PerformersStep: "the event's listed performers",
+	"P":            "a listed performer",
+}
+
+const (
+	HeadlinerRole = "headliner"
+	SupportRole   = "support"
+	PresenterRole = "presenter"
+)
+
 type ArtistGuess struct {
 	Step     string
 	Name     string
+	Role     string
 	Children []*ArtistGuess
 }
 
+var presentsCue = regexp.MustCompile("(?i)^\\s*PRESENTS?\\s*$")
+
+// RoleRank orders headliners before support acts and presenters.
+func RoleRank(role string) int {
+	switch role {
+	case HeadlinerRole:
+		return 0
+	case SupportRole:
+		return 1
+	}
+	return 2
+}
+
+// BillHeadliner picks the headlining part of a split title. Bills list the
+// headliner first and support after "with", "w/" or "special guest", unless
+// someone "presents" them in which case the headliner comes after that.
+func BillHeadliner(parts []string, separators []string) int {
+	headliner := 0
+	for i, separator := range separators {
+		if presentsCue.MatchString(separator) {
+			headliner = i + 1
+		}
+	}
+
+	for i := headliner; i < len(parts); i++ {
+		if len(strings.TrimSpace(parts[i])) > 0 {
+			return i
+		}
+	}
+	for i, part := range parts {
+		if len(strings.TrimSpace(part)) > 0 {
+			return i
+		}
+	}
+
+	return headliner
+}
+
+func BillRole(position int, headliner int) string {
+	if position < headliner {
+		return PresenterRole
+	}
+	if position == headliner {
+		return HeadlinerRole
+	}
+	return SupportRole
+}
+
 func FlattenArtist(root *ArtistGuess) (artists []*ArtistGuess) {
 	artists = append(artists, root.Children...)
 
@@ -21,10 +90,35 @@ func FlattenArtist(root *ArtistGuess) (artists []*ArtistGuess) {
 	return
 }
 
+// GuessPath is the Steps from root down to guess, leaving out the title's own
+// empty step. It's nil when guess isn't in the tree.
+func GuessPath(root *ArtistGuess, guess *ArtistGuess) []string {
+	if root == nil {
+		return nil
+	}
+
+	steps := make([]string, 0)
+	if root.Step != "" {
+		steps = append(steps, root.Step)
+	}
+
+	if root == guess {
+		return steps
+	}
+
+	for _, child := range root.Children {
+		if path := GuessPath(child, guess); path != nil {
+			return append(steps, path...)
+		}
+	}
+
+	return nil
+}
+
 func SwapAndsPermutation(guess *ArtistGuess) {
 	andSwapped := regexp.MustCompile("&").ReplaceAllString(guess.Name, "and")
 	if andSwapped != guess.Name {
-		newGuess := ArtistGuess{Step: "SAP", Name: strings.TrimSpace(andSwapped)}
+		newGuess := ArtistGuess{Step: "SAP", Name: strings.TrimSpace(andSwapped), Role: guess.Role}
 		if len(newGuess.Name) > 0 {
 			guess.Children = append(guess.Children, &newGuess)
 		}
@@ -37,7 +131,7 @@ func SeparateMultipleArtistsPermutation(guess *ArtistGuess) {
 		for _, name := range moreNames {
 			name = strings.TrimSpace(name)
 			if len(name) > 0 {
-				guess.Children = append(guess.Children, &ArtistGuess{Step: "SMA", Name: name})
+				guess.Children = append(guess.Children, &ArtistGuess{Step: "SMA", Name: name, Role: guess.Role})
 			}
 		}
 	}
@@ -48,8 +142,9 @@ func InitialPermutation(guess *ArtistGuess) {
 	split := splitRe.Split(guess.Name, -1)
 	// fmt.Printf("IP: %s %d\n", split, len(split))
 	if len(split) > 1 {
-		for _, substring := range split {
-			child := ArtistGuess{Step: "I", Name: strings.TrimSpace(substring)}
+		headliner := BillHeadliner(split, splitRe.FindAllString(guess.Name, -1))
+		for i, substring := range split {
+			child := ArtistGuess{Step: "I", Name: strings.TrimSpace(substring), Role: BillRole(i, headliner)}
 			if len(child.Name) > 0 {
 				SwapAndsPermutation(&child)
 				SeparateMultipleArtistsPermutation(&child)
@@ -62,7 +157,7 @@ func InitialPermutation(guess *ArtistGuess) {
 func RemoveVenuePermutation(guess *ArtistGuess) {
 	anotherName := regexp.MustCompile("(?i)(\\s+AT\\s+.+)").ReplaceAllString(guess.Name, "")
 	if anotherName != guess.Name {
-		newGuess := ArtistGuess{Step: "RV", Name: anotherName}
+		newGuess := ArtistGuess{Step: "RV", Name: anotherName, Role: guess.Role}
 		InitialPermutation(&newGuess)
 		guess.Children = append(guess.Children, &newGuess)
 	}
@@ -82,7 +177,7 @@ func GuessArtistsForEvent(title string) (guess *ArtistGuess) {
 		"�",
 	}
 
-	guess = &ArtistGuess{Step: "", Name: title}
+	guess = &ArtistGuess{Step: "", Name: title, Role: HeadlinerRole}
 
 	for _, pattern := range patternsToRemove {
 		r := regexp.MustCompile("(?i)" + pattern)
@@ -90,10 +185,28 @@ func GuessArtistsForEvent(title string) (guess *ArtistGuess) {
 	}
 
 	title = strings.TrimSpace(title)
-	cleaned := ArtistGuess{Step: "C", Name: title}
+	cleaned := ArtistGuess{Step: "C", Name: title, Role: HeadlinerRole}
 	InitialPermutation(&cleaned)
 	RemoveVenuePermutation(&cleaned)
 	guess.Children = append(guess.Children, &cleaned)
 
 	return guess
 }
+
+func GuessArtistsForPerformers(title string, performers []string) (guess *ArtistGuess) {
+	guess = &ArtistGuess{Step: PerformersStep, Name: title, Role: HeadlinerRole}
+
+	// Performers are listed headliner first.
+	for _, performer := range performers {
+		performer = strings.TrimSpace(performer)
+		if len(performer) > 0 {
+			role := SupportRole
+			if len(guess.Children) == 0 {
+				role = HeadlinerRole
+			}
+			guess.Children = append(guess.Children, &ArtistGuess{Step: "P", Name: performer, Role: role})
+		}
+	}
+
+	return guess
+}
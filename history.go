@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zmb3/spotify"
+)
+
+// HistoryRecord is one run, appended to the history file as a line of JSON.
+type HistoryRecord struct {
+	Started time.Time
+	Seconds float64
+	DryRun  bool
+	Regions []HistoryRegion
+	Errors  []string `json:",omitempty"`
+}
+
+// HistoryRegion's Added and Removed are the diff of the region's playlist.
+type HistoryRegion struct {
+	Region     string
+	Playlist   string
+	PlaylistId spotify.ID `json:",omitempty"`
+	Seconds    float64
+	Added      []PlannedTrack
+	Removed    []PlannedTrack
+	Errors     []string `json:",omitempty"`
+	Venues     []HistoryVenue
+}
+
+type HistoryVenue struct {
+	Venue   string
+	Seconds float64
+	Events  []HistoryEvent
+}
+
+type HistoryEvent struct {
+	Name      string
+	Venue     string
+	StartTime time.Time
+	Artists   []HistoryArtist
+	Tracks    []spotify.ID
+}
+
+// HistoryArtist's Path is the Steps of the guesses that found them, say
+// C/I/SMA.
+type HistoryArtist struct {
+	Guess    string
+	Path     []string
+	Role     string
+	ArtistId spotify.ID
+	Name     string
+}
+
+// UnresolvedEvent is an event that no run found tracks for.
+type UnresolvedEvent struct {
+	Region    string
+	Venue     string
+	Name      string
+	StartTime time.Time
+	Runs      int
+}
+
+func NewHistoryRecord(report *RunReport, finished time.Time) *HistoryRecord {
+	record := &HistoryRecord{
+		Started: report.Started,
+		Seconds: finished.Sub(report.Started).Seconds(),
+		DryRun:  report.DryRun,
+		Regions: make([]HistoryRegion, 0),
+		Errors:  report.Errors,
+	}
+
+	for _, rr := range report.Regions {
+		region := HistoryRegion{
+			Region:     rr.Region,
+			Playlist:   rr.Playlist,
+			PlaylistId: rr.PlaylistId,
+			Seconds:    rr.Elapsed.Seconds(),
+			Added:      rr.Added,
+			Removed:    rr.Removed,
+			Errors:     rr.Errors,
+			Venues:     make([]HistoryVenue, 0),
+		}
+
+		for _, res := range rr.Venues {
+			venue := HistoryVenue{
+				Venue:   res.VenueName,
+				Seconds: res.Elapsed.Seconds(),
+				Events:  make([]HistoryEvent, 0),
+			}
+
+			for _, resolved := range res.Events {
+				event := HistoryEvent{
+					Name:      resolved.Event.Name,
+					Venue:     resolved.Event.Venue,
+					StartTime: resolved.Event.StartTime,
+					Artists:   make([]HistoryArtist, 0),
+					Tracks:    make([]spotify.ID, 0),
+				}
+				for _, artist := range resolved.Artists {
+					event.Artists = append(event.Artists, HistoryArtist{
+						Guess:    artist.Guess,
+						Path:     artist.Path,
+						Role:     artist.Role,
+						ArtistId: artist.Artist.ID,
+						Name:     artist.Artist.Name,
+					})
+				}
+				for _, track := range resolved.Tracks {
+					event.Tracks = append(event.Tracks, track.Id)
+				}
+				venue.Events = append(venue.Events, event)
+			}
+
+			region.Venues = append(region.Venues, venue)
+		}
+
+		record.Regions = append(record.Regions, region)
+	}
+
+	return record
+}
+
+// AppendHistory starts a new line if the last run was cut off part way
+// through its line, so this one isn't lost along with it.
+func AppendHistory(fileName string, record *HistoryRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+			return err
+		}
+		if last[0] != '\n' {
+			data = append([]byte{'\n'}, data...)
+		}
+	}
+
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// LoadHistory is every run in the file, oldest first. A missing file is no
+// history. Lines that don't parse are runs that were cut off while they were
+// being written and are skipped.
+func LoadHistory(fileName string) ([]*HistoryRecord, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make([]*HistoryRecord, 0), nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	records := make([]*HistoryRecord, 0)
+	reader := bufio.NewReader(file)
+	for number := 1; ; number++ {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return nil, readErr
+		}
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			record := &HistoryRecord{}
+			if err := json.Unmarshal(line, record); err != nil {
+				log.Printf("WARNING: Skipping line %d of %s: %v", number, fileName, err)
+			} else {
+				records = append(records, record)
+			}
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	return records, nil
+}
+
+func (r *HistoryRegion) Counts() (counts ReportCounts) {
+	counts.Venues = len(r.Venues)
+	for _, venue := range r.Venues {
+		for _, event := range venue.Events {
+			counts.Events++
+			counts.Artists += len(event.Artists)
+			counts.Tracks += len(event.Tracks)
+			if len(event.Tracks) == 0 {
+				counts.Unresolved++
+			}
+		}
+	}
+	counts.Added = len(r.Added)
+	counts.Removed = len(r.Removed)
+	return
+}
+
+// FilterHistory keeps the runs started after since, and only region's part
+// of them when region isn't empty.
+func FilterHistory(records []*HistoryRecord, since time.Time, region string) []*HistoryRecord {
+	filtered := make([]*HistoryRecord, 0)
+	for _, record := range records {
+		if record.Started.Before(since) {
+			continue
+		}
+
+		if region != "" {
+			regions := make([]HistoryRegion, 0)
+			for _, r := range record.Regions {
+				if strings.EqualFold(r.Region, region) {
+					regions = append(regions, r)
+				}
+			}
+			if len(regions) == 0 {
+				continue
+			}
+			copied := *record
+			copied.Regions = regions
+			record = &copied
+		}
+
+		filtered = append(filtered, record)
+	}
+	return filtered
+}
+
+// NeverResolved are the events that came up in records without any of those
+// runs finding tracks for them, soonest first.
+func NeverResolved(records []*HistoryRecord) []*UnresolvedEvent {
+	events := make(map[string]*UnresolvedEvent)
+	resolved := make(map[string]bool)
+
+	for _, record := range records {
+		for _, region := range record.Regions {
+			for _, venue := range region.Venues {
+				for _, event := range venue.Events {
+					key := strings.Join([]string{region.Region, event.Venue, event.Name, event.StartTime.Format(time.RFC3339)}, "|")
+					if len(event.Tracks) > 0 {
+						resolved[key] = true
+					}
+					if _, ok := events[key]; !ok {
+						events[key] = &UnresolvedEvent{
+							Region:    region.Region,
+							Venue:     event.Venue,
+							Name:      event.Name,
+							StartTime: event.StartTime,
+						}
+					}
+					events[key].Runs++
+				}
+			}
+		}
+	}
+
+	unresolved := make([]*UnresolvedEvent, 0)
+	for key, event := range events {
+		if !resolved[key] {
+			unresolved = append(unresolved, event)
+		}
+	}
+
+	sort.Slice(unresolved, func(i, j int) bool {
+		if !unresolved[i].StartTime.Equal(unresolved[j].StartTime) {
+			return unresolved[i].StartTime.Before(unresolved[j].StartTime)
+		}
+		return unresolved[i].Name < unresolved[j].Name
+	})
+
+	return unresolved
+}
+
+func ListHistory(w io.Writer, records []*HistoryRecord) {
+	for _, record := range records {
+		dryRun := ""
+		if record.DryRun {
+			dryRun = " (dry run)"
+		}
+		fmt.Fprintf(w, "%s %6.1fs%s\n", record.Started.Format("2006-01-02 15:04"), record.Seconds, dryRun)
+		for _, err := range record.Errors {
+			fmt.Fprintf(w, "   %s\n", err)
+		}
+		for _, region := range record.Regions {
+			counts := region.Counts()
+			fmt.Fprintf(w, "   %-20s %3d events, %3d unresolved, %3d added, %3d removed %6.1fs\n", region.Region, counts.Events, counts.Unresolved, counts.Added, counts.Removed, region.Seconds)
+			for _, err := range region.Errors {
+				fmt.Fprintf(w, "      %s\n", err)
+			}
+		}
+	}
+}
+
+func ListUnresolved(w io.Writer, unresolved []*UnresolvedEvent) {
+	for _, event := range unresolved {
+		fmt.Fprintf(w, "%s %-20s %-30s %s (%d runs)\n", event.StartTime.Format("2006-01-02"), event.Region, event.Venue, event.Name, event.Runs)
+	}
+}
+
+// HistoryCommand lists past runs, or with -unresolved the events none of them
+// found tracks for.
+func HistoryCommand(fileName string, args []string, now time.Time) error {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	weeks := flags.Int("weeks", 4, "only runs in this many weeks, 0 for all of them")
+	region := flags.String("region", "", "only this region")
+	unresolved := flags.Bool("unresolved", false, "list events that were never resolved")
+	asJson := flags.Bool("json", false, "print as json lines")
+	flags.Parse(args)
+
+	records, err := LoadHistory(fileName)
+	if err != nil {
+		return err
+	}
+
+	since := time.Time{}
+	if *weeks > 0 {
+		since = now.AddDate(0, 0, -7*(*weeks))
+	}
+	records = FilterHistory(records, since, *region)
+
+	var values []interface{}
+	if *unresolved {
+		events := NeverResolved(records)
+		if !*asJson {
+			ListUnresolved(os.Stdout, events)
+			return nil
+		}
+		for _, event := range events {
+			values = append(values, event)
+		}
+	} else {
+		if !*asJson {
+			ListHistory(os.Stdout, records)
+			return nil
+		}
+		for _, record := range records {
+			values = append(values, record)
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	for _, value := range values {
+		if err := encoder.Encode(value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
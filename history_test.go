@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zmb3/spotify"
+)
+
+func newTestHistoryRecord(started time.Time, region string, events ...HistoryEvent) *HistoryRecord {
+	return &HistoryRecord{
+		Started: started,
+		Regions: []HistoryRegion{
+			{Region: region, Venues: []HistoryVenue{{Venue: "Cafe Nine", Events: events}}},
+		},
+	}
+}
+
+func newTestHistoryEvent(name string, day int, tracks ...spotify.ID) HistoryEvent {
+	return HistoryEvent{
+		Name:      name,
+		Venue:     "Cafe Nine",
+		StartTime: time.Date(2017, 6, day, 21, 0, 0, 0, time.UTC),
+		Tracks:    tracks,
+	}
+}
+
+func TestFilterHistory(t *testing.T) {
+	first := time.Date(2017, 6, 1, 6, 0, 0, 0, time.UTC)
+	second := first.AddDate(0, 0, 7)
+
+	records := []*HistoryRecord{
+		newTestHistoryRecord(first, "New Haven"),
+		{
+			Started: second,
+			Regions: []HistoryRegion{{Region: "New Haven"}, {Region: "los angeles"}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		since    time.Time
+		region   string
+		expected []string
+	}{
+		{"everything", time.Time{}, "", []string{"New Haven", "New Haven los angeles"}},
+		{"since is inclusive", second, "", []string{"New Haven los angeles"}},
+		{"after the last run", second.Add(time.Second), "", []string{}},
+		{"region ignores case", time.Time{}, "new haven", []string{"New Haven", "New Haven"}},
+		{"runs without the region are dropped", time.Time{}, "Los Angeles", []string{"los angeles"}},
+		{"unknown region", time.Time{}, "boston", []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual := make([]string, 0)
+			for _, record := range FilterHistory(records, test.since, test.region) {
+				regions := make([]string, 0)
+				for _, region := range record.Regions {
+					regions = append(regions, region.Region)
+				}
+				actual = append(actual, strings.Join(regions, " "))
+			}
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+
+	if len(records[1].Regions) != 2 {
+		t.Errorf("Expected filtering to leave the records alone")
+	}
+}
+
+func TestNeverResolved(t *testing.T) {
+	first := time.Date(2017, 6, 1, 6, 0, 0, 0, time.UTC)
+	second := first.AddDate(0, 0, 1)
+
+	records := []*HistoryRecord{
+		newTestHistoryRecord(first, "new haven",
+			newTestHistoryEvent("Trivia Night", 4),
+			newTestHistoryEvent("Zombii", 2),
+			newTestHistoryEvent("RYXNO", 3),
+		),
+		newTestHistoryRecord(second, "new haven",
+			newTestHistoryEvent("Trivia Night", 4),
+			newTestHistoryEvent("Zombii", 2),
+			newTestHistoryEvent("RYXNO", 3, "r1"),
+			newTestHistoryEvent("Open Mic", 4),
+		),
+		// The same show in another region is another event.
+		newTestHistoryRecord(second, "los angeles",
+			newTestHistoryEvent("RYXNO", 3),
+		),
+	}
+
+	actual := make([]string, 0)
+	for _, event := range NeverResolved(records) {
+		actual = append(actual, fmt.Sprintf("%s %s %s %d", event.Region, event.Name, event.StartTime.Format("01/02"), event.Runs))
+	}
+
+	expected := []string{
+		"new haven Zombii 06/02 2",
+		"los angeles RYXNO 06/03 1",
+		"new haven Open Mic 06/04 1",
+		"new haven Trivia Night 06/04 2",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
+
+func TestLoadHistorySkipsIncompleteLines(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "history.jsonl")
+	started := time.Date(2017, 6, 1, 6, 0, 0, 0, time.UTC)
+
+	if records, err := LoadHistory(fileName); err != nil || len(records) != 0 {
+		t.Fatalf("Expected no history, got %v: %v", records, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := AppendHistory(fileName, newTestHistoryRecord(started.AddDate(0, 0, i), "new haven")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A run that crashed part way through writing its line.
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(`{"Started":"2017-06-03T06:00:00Z","Regi`); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	records, err := LoadHistory(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Errorf("Expected the incomplete line to be skipped, got %d runs", len(records))
+	}
+
+	if err := AppendHistory(fileName, newTestHistoryRecord(started.AddDate(0, 0, 3), "new haven")); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err = LoadHistory(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || !records[2].Started.Equal(started.AddDate(0, 0, 3)) {
+		t.Errorf("Expected the next run to be kept after the incomplete line, got %d runs", len(records))
+	}
+}
@@ -0,0 +1,597 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Calendar struct {
+	Events []CalendarEvent
+}
+
+type CalendarEvent struct {
+	Uid          string
+	Summary      string
+	Location     string
+	Url          string
+	Start        time.Time
+	End          time.Time
+	AllDay       bool
+	Rule         *RecurrenceRule
+	RDates       []time.Time
+	ExDates      []time.Time
+	RecurrenceId time.Time
+}
+
+type RecurrenceRule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+}
+
+type WeekdayNum struct {
+	N       int
+	Weekday time.Weekday
+}
+
+type ContentLine struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// maximumRecurrences caps how many occurrences of one rule are expanded into
+// a range, occurrences before the range don't count towards it.
+const maximumRecurrences = 1000
+
+var icsWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+func UnfoldLines(data string) (lines []string) {
+	data = strings.Replace(data, "\r\n", "\n", -1)
+	for _, line := range strings.Split(data, "\n") {
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return
+}
+
+func ParseContentLine(line string) (cl ContentLine, err error) {
+	quoted := false
+	colon := -1
+	for i, c := range line {
+		if c == '"' {
+			quoted = !quoted
+		} else if c == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return cl, fmt.Errorf("Malformed line '%s'", line)
+	}
+
+	cl.Value = line[colon+1:]
+	cl.Params = make(map[string]string)
+
+	parts := strings.Split(line[:colon], ";")
+	cl.Name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) == 2 {
+			cl.Params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], "\"")
+		}
+	}
+
+	return
+}
+
+var icsTextEscapes = strings.NewReplacer("\\\\", "\\", "\\;", ";", "\\,", ",", "\\n", "\n", "\\N", "\n")
+
+func UnescapeText(value string) string {
+	return strings.TrimSpace(icsTextEscapes.Replace(value))
+}
+
+func LoadCalendarLocation(tzid string) *time.Location {
+	if tzid == "" {
+		return time.Local
+	}
+
+	if location, err := time.LoadLocation(tzid); err == nil {
+		return location
+	}
+
+	// Some feeds prefix the Olson name, e.g. /mozilla.org/20050126_1/America/New_York
+	parts := strings.Split(strings.Trim(tzid, "/"), "/")
+	for i := 1; i < len(parts); i++ {
+		if location, err := time.LoadLocation(strings.Join(parts[i:], "/")); err == nil {
+			return location
+		}
+	}
+
+	return time.Local
+}
+
+func ParseCalendarValue(value string, location *time.Location) (t time.Time, allDay bool, err error) {
+	if len(value) == len("20060102") {
+		t, err = time.ParseInLocation("20060102", value, location)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse("20060102T150405Z", value)
+		return
+	}
+
+	t, err = time.ParseInLocation("20060102T150405", value, location)
+	return
+}
+
+func ParseCalendarTime(cl ContentLine) (t time.Time, allDay bool, err error) {
+	location := LoadCalendarLocation(cl.Params["TZID"])
+	if cl.Params["VALUE"] == "DATE" {
+		t, err = time.ParseInLocation("20060102", cl.Value, location)
+		return t, true, err
+	}
+
+	return ParseCalendarValue(cl.Value, location)
+}
+
+func ParseCalendarTimes(cl ContentLine) (times []time.Time, err error) {
+	location := LoadCalendarLocation(cl.Params["TZID"])
+	for _, value := range strings.Split(cl.Value, ",") {
+		if strings.Contains(value, "/") {
+			value = strings.SplitN(value, "/", 2)[0]
+		}
+		t, _, err := ParseCalendarValue(value, location)
+		if err != nil {
+			return nil, err
+		}
+		times = append(times, t)
+	}
+
+	return
+}
+
+func ParseDuration(value string) (d time.Duration, err error) {
+	sign := time.Duration(1)
+	if strings.HasPrefix(value, "-") {
+		sign = -1
+	}
+	value = strings.TrimLeft(value, "+-")
+	if !strings.HasPrefix(value, "P") {
+		return 0, fmt.Errorf("Malformed duration '%s'", value)
+	}
+
+	number := ""
+	for _, c := range value[1:] {
+		if c >= '0' && c <= '9' {
+			number += string(c)
+			continue
+		}
+
+		n := 0
+		if number != "" {
+			n, _ = strconv.Atoi(number)
+		}
+		number = ""
+
+		switch c {
+		case 'W':
+			d += time.Duration(n) * 7 * 24 * time.Hour
+		case 'D':
+			d += time.Duration(n) * 24 * time.Hour
+		case 'H':
+			d += time.Duration(n) * time.Hour
+		case 'M':
+			d += time.Duration(n) * time.Minute
+		case 'S':
+			d += time.Duration(n) * time.Second
+		case 'T':
+		default:
+			return 0, fmt.Errorf("Malformed duration '%s'", value)
+		}
+	}
+
+	return sign * d, nil
+}
+
+func ParseRecurrenceRule(value string, location *time.Location) (rule *RecurrenceRule, err error) {
+	rule = &RecurrenceRule{Interval: 1}
+
+	for _, part := range strings.Split(value, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		switch strings.ToUpper(kv[0]) {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(kv[1])
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(kv[1])
+			if err != nil || rule.Interval < 1 {
+				return nil, fmt.Errorf("Malformed INTERVAL '%s'", kv[1])
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(kv[1])
+			if err != nil {
+				return nil, fmt.Errorf("Malformed COUNT '%s'", kv[1])
+			}
+		case "UNTIL":
+			rule.Until, _, err = ParseCalendarValue(kv[1], location)
+			if err != nil {
+				return nil, fmt.Errorf("Malformed UNTIL '%s'", kv[1])
+			}
+		case "BYDAY":
+			for _, day := range strings.Split(kv[1], ",") {
+				day = strings.ToUpper(strings.TrimSpace(day))
+				if len(day) < 2 {
+					return nil, fmt.Errorf("Malformed BYDAY '%s'", kv[1])
+				}
+				weekday, ok := icsWeekdays[day[len(day)-2:]]
+				if !ok {
+					return nil, fmt.Errorf("Malformed BYDAY '%s'", kv[1])
+				}
+				n := 0
+				if len(day) > 2 {
+					n, err = strconv.Atoi(day[:len(day)-2])
+					if err != nil {
+						return nil, fmt.Errorf("Malformed BYDAY '%s'", kv[1])
+					}
+				}
+				rule.ByDay = append(rule.ByDay, WeekdayNum{N: n, Weekday: weekday})
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(kv[1], ",") {
+				n, err := strconv.Atoi(day)
+				if err != nil {
+					return nil, fmt.Errorf("Malformed BYMONTHDAY '%s'", kv[1])
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, month := range strings.Split(kv[1], ",") {
+				n, err := strconv.Atoi(month)
+				if err != nil || n < 1 || n > 12 {
+					return nil, fmt.Errorf("Malformed BYMONTH '%s'", kv[1])
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(n))
+			}
+		}
+	}
+
+	switch rule.Freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	default:
+		return nil, fmt.Errorf("Unsupported FREQ '%s'", rule.Freq)
+	}
+
+	return rule, nil
+}
+
+func ParseCalendar(data []byte) (calendar *Calendar, err error) {
+	calendar = &Calendar{}
+
+	var current *CalendarEvent
+	var duration time.Duration
+	var rrule *ContentLine
+	for _, line := range UnfoldLines(string(data)) {
+		cl, err := ParseContentLine(line)
+		if err != nil {
+			log.Printf("Skipping: %v", err)
+			continue
+		}
+
+		switch {
+		case cl.Name == "BEGIN" && strings.ToUpper(cl.Value) == "VEVENT":
+			current = &CalendarEvent{}
+			duration = 0
+			rrule = nil
+		case cl.Name == "END" && strings.ToUpper(cl.Value) == "VEVENT":
+			if current != nil {
+				if current.End.IsZero() {
+					if duration == 0 && current.AllDay {
+						duration = 24 * time.Hour
+					}
+					current.End = current.Start.Add(duration)
+				}
+				if rrule != nil {
+					current.Rule, err = ParseRecurrenceRule(rrule.Value, current.Start.Location())
+					if err != nil {
+						return nil, fmt.Errorf("Unable to parse RRULE for '%s': %v", current.Summary, err)
+					}
+				}
+				calendar.Events = append(calendar.Events, *current)
+			}
+			current = nil
+		case current == nil:
+		case cl.Name == "UID":
+			current.Uid = cl.Value
+		case cl.Name == "SUMMARY":
+			current.Summary = UnescapeText(cl.Value)
+		case cl.Name == "LOCATION":
+			current.Location = UnescapeText(cl.Value)
+		case cl.Name == "URL":
+			current.Url = strings.TrimSpace(cl.Value)
+		case cl.Name == "DTSTART":
+			current.Start, current.AllDay, err = ParseCalendarTime(cl)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to parse DTSTART '%s': %v", cl.Value, err)
+			}
+		case cl.Name == "DTEND":
+			current.End, _, err = ParseCalendarTime(cl)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to parse DTEND '%s': %v", cl.Value, err)
+			}
+		case cl.Name == "DURATION":
+			duration, err = ParseDuration(cl.Value)
+			if err != nil {
+				return nil, err
+			}
+		case cl.Name == "RRULE":
+			rrule = &cl
+		case cl.Name == "RDATE":
+			times, err := ParseCalendarTimes(cl)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to parse RDATE '%s': %v", cl.Value, err)
+			}
+			current.RDates = append(current.RDates, times...)
+		case cl.Name == "EXDATE":
+			times, err := ParseCalendarTimes(cl)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to parse EXDATE '%s': %v", cl.Value, err)
+			}
+			current.ExDates = append(current.ExDates, times...)
+		case cl.Name == "RECURRENCE-ID":
+			current.RecurrenceId, _, err = ParseCalendarTime(cl)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to parse RECURRENCE-ID '%s': %v", cl.Value, err)
+			}
+		}
+	}
+
+	return
+}
+
+// Occurrences expands recurring events and returns every instance that
+// overlaps the given range, in start order. Instances replaced by a
+// RECURRENCE-ID override are dropped in favor of the override.
+func (c *Calendar) Occurrences(from, to time.Time) (occurrences []CalendarEvent) {
+	overrides := make(map[string]bool)
+	for _, ve := range c.Events {
+		if !ve.RecurrenceId.IsZero() {
+			overrides[ve.Uid+"/"+ve.RecurrenceId.UTC().Format(time.RFC3339)] = true
+		}
+	}
+
+	for _, ve := range c.Events {
+		if !ve.RecurrenceId.IsZero() {
+			if ve.Overlaps(from, to) {
+				occurrences = append(occurrences, ve)
+			}
+			continue
+		}
+
+		for _, start := range ve.ExpandStarts(from, to) {
+			if overrides[ve.Uid+"/"+start.UTC().Format(time.RFC3339)] {
+				continue
+			}
+
+			instance := ve
+			instance.End = start.Add(ve.End.Sub(ve.Start))
+			instance.Start = start
+			if instance.Overlaps(from, to) {
+				occurrences = append(occurrences, instance)
+			}
+		}
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].Start.Before(occurrences[j].Start)
+	})
+
+	return
+}
+
+// Overlaps follows GetUpcomingFacebookEvents for timed events, which only
+// counts shows that start inside the range. All-day events count for as long
+// as the day lasts.
+func (ve *CalendarEvent) Overlaps(from, to time.Time) bool {
+	if ve.AllDay {
+		return ve.End.After(from) && ve.Start.Before(to)
+	}
+	return !ve.Start.Before(from) && ve.Start.Before(to)
+}
+
+// ExpandStarts is every start of the event that could overlap from to until,
+// including those that start before from and are still going.
+func (ve *CalendarEvent) ExpandStarts(from, until time.Time) (starts []time.Time) {
+	excluded := make(map[int64]bool)
+	for _, t := range ve.ExDates {
+		excluded[t.Unix()] = true
+	}
+
+	add := func(t time.Time) {
+		if !excluded[t.Unix()] {
+			starts = append(starts, t)
+		}
+	}
+
+	if ve.Rule == nil {
+		add(ve.Start)
+	} else {
+		for _, t := range ve.Rule.Expand(ve.Start, from.Add(-ve.End.Sub(ve.Start)), until) {
+			add(t)
+		}
+	}
+
+	for _, t := range ve.RDates {
+		add(t)
+	}
+
+	return
+}
+
+// Expand is the starts between from and until of a rule that began at start.
+// COUNT counts every occurrence since start, but only those from on count
+// towards maximumRecurrences.
+func (rule *RecurrenceRule) Expand(start, from, until time.Time) (starts []time.Time) {
+	if !rule.Until.IsZero() && rule.Until.Before(until) {
+		until = rule.Until
+	}
+
+	count := 0
+	for period := 0; len(starts) < maximumRecurrences; period++ {
+		candidates := rule.PeriodCandidates(start, period)
+		if len(candidates) == 0 && rule.PeriodStart(start, period).After(until) {
+			break
+		}
+
+		for _, t := range candidates {
+			if t.Before(start) {
+				continue
+			}
+			if t.After(until) || (rule.Count > 0 && count >= rule.Count) {
+				return
+			}
+			count++
+			if t.Before(from) {
+				continue
+			}
+			starts = append(starts, t)
+		}
+
+		if rule.PeriodStart(start, period).After(until) {
+			break
+		}
+	}
+
+	return
+}
+
+func (rule *RecurrenceRule) PeriodStart(start time.Time, period int) time.Time {
+	n := period * rule.Interval
+	switch rule.Freq {
+	case "DAILY":
+		return start.AddDate(0, 0, n)
+	case "WEEKLY":
+		return start.AddDate(0, 0, 7*n)
+	case "MONTHLY":
+		return time.Date(start.Year(), start.Month()+time.Month(n), 1, 0, 0, 0, 0, start.Location())
+	}
+	return time.Date(start.Year()+n, time.January, 1, 0, 0, 0, 0, start.Location())
+}
+
+func (rule *RecurrenceRule) PeriodCandidates(start time.Time, period int) (candidates []time.Time) {
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+	}
+
+	periodStart := rule.PeriodStart(start, period)
+
+	switch rule.Freq {
+	case "DAILY":
+		candidates = append(candidates, at(periodStart.Year(), periodStart.Month(), periodStart.Day()))
+	case "WEEKLY":
+		if len(rule.ByDay) == 0 {
+			candidates = append(candidates, at(periodStart.Year(), periodStart.Month(), periodStart.Day()))
+			break
+		}
+		offset := (int(periodStart.Weekday()) + 6) % 7
+		monday := periodStart.AddDate(0, 0, -offset)
+		for _, wd := range rule.ByDay {
+			day := monday.AddDate(0, 0, (int(wd.Weekday)+6)%7)
+			candidates = append(candidates, at(day.Year(), day.Month(), day.Day()))
+		}
+	case "MONTHLY":
+		candidates = rule.MonthCandidates(start, periodStart.Year(), periodStart.Month(), at)
+	case "YEARLY":
+		months := rule.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{start.Month()}
+		}
+		for _, month := range months {
+			candidates = append(candidates, rule.MonthCandidates(start, periodStart.Year(), month, at)...)
+		}
+	}
+
+	if len(rule.ByMonth) > 0 && rule.Freq != "YEARLY" {
+		filtered := candidates[:0]
+		for _, t := range candidates {
+			for _, month := range rule.ByMonth {
+				if t.Month() == month {
+					filtered = append(filtered, t)
+					break
+				}
+			}
+		}
+		candidates = filtered
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Before(candidates[j])
+	})
+
+	return
+}
+
+func (rule *RecurrenceRule) MonthCandidates(start time.Time, year int, month time.Month, at func(int, time.Month, int) time.Time) (candidates []time.Time) {
+	daysInMonth := time.Date(year, month+1, 0, 0, 0, 0, 0, start.Location()).Day()
+
+	for _, day := range rule.ByMonthDay {
+		if day < 0 {
+			day = daysInMonth + day + 1
+		}
+		if day >= 1 && day <= daysInMonth {
+			candidates = append(candidates, at(year, month, day))
+		}
+	}
+
+	for _, wd := range rule.ByDay {
+		var matching []int
+		for day := 1; day <= daysInMonth; day++ {
+			if time.Date(year, month, day, 0, 0, 0, 0, start.Location()).Weekday() == wd.Weekday {
+				matching = append(matching, day)
+			}
+		}
+
+		switch {
+		case wd.N > 0 && wd.N <= len(matching):
+			candidates = append(candidates, at(year, month, matching[wd.N-1]))
+		case wd.N < 0 && -wd.N <= len(matching):
+			candidates = append(candidates, at(year, month, matching[len(matching)+wd.N]))
+		case wd.N == 0:
+			for _, day := range matching {
+				candidates = append(candidates, at(year, month, day))
+			}
+		}
+	}
+
+	if len(rule.ByMonthDay) == 0 && len(rule.ByDay) == 0 && start.Day() <= daysInMonth {
+		candidates = append(candidates, at(year, month, start.Day()))
+	}
+
+	return
+}
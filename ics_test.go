@@ -0,0 +1,135 @@
+package main
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func loadCalendar(t *testing.T, fileName string) *Calendar {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calendar, err := ParseCalendar(data)
+	if err != nil {
+		t.Fatalf("Unable to parse %s: %v", fileName, err)
+	}
+
+	return calendar
+}
+
+func TestOccurrences(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		fileName string
+		from     time.Time
+		to       time.Time
+		expected []string
+	}{
+		{
+			name:     "weekly rule with EXDATE and RECURRENCE-ID",
+			fileName: "testdata/recurring.ics",
+			from:     time.Date(2017, 6, 1, 0, 0, 0, 0, newYork),
+			to:       time.Date(2017, 7, 1, 0, 0, 0, 0, newYork),
+			expected: []string{
+				"Trivia Night 2017-06-01T20:00 EDT",
+				"Trivia Night 2017-06-08T20:00 EDT",
+				"RYXNO, Zombii 2017-06-10T21:00 EDT",
+				"Trivia Night (late) 2017-06-22T21:00 EDT",
+				"Trivia Night 2017-06-29T20:00 EDT",
+			},
+		},
+		{
+			name:     "window lower bound is inclusive",
+			fileName: "testdata/recurring.ics",
+			from:     time.Date(2017, 6, 8, 20, 0, 0, 0, newYork),
+			to:       time.Date(2017, 6, 9, 0, 0, 0, 0, newYork),
+			expected: []string{
+				"Trivia Night 2017-06-08T20:00 EDT",
+			},
+		},
+		{
+			name:     "rules that started long before the window",
+			fileName: "testdata/old.ics",
+			from:     time.Date(2017, 6, 1, 0, 0, 0, 0, newYork),
+			to:       time.Date(2017, 6, 4, 0, 0, 0, 0, newYork),
+			expected: []string{
+				"Residency 2017-06-01T19:00 UTC",
+				"Open Mic 2017-06-01T23:00 UTC",
+				"Open Mic 2017-06-02T23:00 UTC",
+				"Open Mic 2017-06-03T23:00 UTC",
+			},
+		},
+		{
+			name:     "COUNT is used up before the window",
+			fileName: "testdata/old.ics",
+			from:     time.Date(2017, 6, 2, 0, 0, 0, 0, time.UTC),
+			to:       time.Date(2017, 6, 3, 0, 0, 0, 0, time.UTC),
+			expected: []string{
+				"Open Mic 2017-06-02T23:00 UTC",
+			},
+		},
+		{
+			name:     "all-day and floating events",
+			fileName: "testdata/floating.ics",
+			from:     time.Date(2017, 6, 2, 0, 0, 0, 0, time.Local),
+			to:       time.Date(2017, 6, 9, 0, 0, 0, 0, time.Local),
+			expected: []string{
+				"Festival 2017-06-01T00:00 " + time.Date(2017, 6, 1, 0, 0, 0, 0, time.Local).Format("MST"),
+				"Floating Show 2017-06-03T21:00 " + time.Date(2017, 6, 3, 21, 0, 0, 0, time.Local).Format("MST"),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calendar := loadCalendar(t, test.fileName)
+
+			actual := make([]string, 0)
+			for _, ve := range calendar.Occurrences(test.from, test.to) {
+				actual = append(actual, ve.Summary+" "+ve.Start.Format("2006-01-02T15:04 MST"))
+			}
+
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestOccurrencesAllDay(t *testing.T) {
+	calendar := loadCalendar(t, "testdata/floating.ics")
+
+	for _, ve := range calendar.Occurrences(time.Date(2017, 6, 1, 0, 0, 0, 0, time.Local), time.Date(2017, 6, 2, 0, 0, 0, 0, time.Local)) {
+		switch ve.Summary {
+		case "Festival":
+			if !ve.AllDay || !ve.End.Equal(time.Date(2017, 6, 3, 0, 0, 0, 0, time.Local)) {
+				t.Errorf("Expected an all-day event until June 3, got %v - %v", ve.Start, ve.End)
+			}
+		case "Picnic":
+			if !ve.AllDay || ve.End.Sub(ve.Start) != 24*time.Hour {
+				t.Errorf("Expected an all-day event lasting a day, got %v - %v", ve.Start, ve.End)
+			}
+		default:
+			t.Errorf("Unexpected '%s'", ve.Summary)
+		}
+	}
+}
+
+func TestOccurrencesFloating(t *testing.T) {
+	calendar := loadCalendar(t, "testdata/floating.ics")
+
+	for _, ve := range calendar.Events {
+		if ve.Summary == "Floating Show" && ve.Start.Location() != time.Local {
+			t.Errorf("Expected a floating time in the local zone, got %v", ve.Start.Location())
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var jsonLdScripts = regexp.MustCompile("(?is)<script[^>]*type\\s*=\\s*[\"']application/ld\\+json[\"'][^>]*>(.*?)</script>")
+
+var jsonLdEventTypes = map[string]bool{
+	"Event":      true,
+	"MusicEvent": true,
+}
+
+var jsonLdDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02T15:04-07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+type JsonLdEventSource struct {
+	venue Venue
+}
+
+func (s *JsonLdEventSource) GetVenue() Venue {
+	return s.venue
+}
+
+func (s *JsonLdEventSource) GetVenueName() (string, error) {
+	return GetVenueDisplayName(s.venue), nil
+}
+
+func (s *JsonLdEventSource) GetUpcomingEvents(window Window) (events []Event, err error) {
+	data, err := ReadVenueResource(s.venue)
+	if err != nil {
+		return nil, err
+	}
+
+	events = ParseJsonLdEvents(data)
+
+	for i := range events {
+		if s.venue.Name != "" || events[i].Venue == "" {
+			events[i].Venue = GetVenueDisplayName(s.venue)
+		}
+	}
+
+	return FilterUpcomingEvents(events, window), nil
+}
+
+// ParseJsonLdEvents skips blocks and events it can't make sense of, pages
+// often have more JSON-LD than just their events.
+func ParseJsonLdEvents(page []byte) (events []Event) {
+	for _, match := range jsonLdScripts.FindAllSubmatch(page, -1) {
+		var block interface{}
+		if err := json.Unmarshal(match[1], &block); err != nil {
+			log.Printf("Skipping JSON-LD: %v", err)
+			continue
+		}
+
+		for _, node := range FindJsonLdEvents(block) {
+			event, err := NewEventFromJsonLd(node)
+			if err != nil {
+				log.Printf("Skipping JSON-LD: %v", err)
+				continue
+			}
+			events = append(events, event)
+		}
+	}
+
+	return
+}
+
+func FindJsonLdEvents(block interface{}) (nodes []map[string]interface{}) {
+	switch value := block.(type) {
+	case []interface{}:
+		for _, item := range value {
+			nodes = append(nodes, FindJsonLdEvents(item)...)
+		}
+	case map[string]interface{}:
+		if IsJsonLdEvent(value["@type"]) {
+			nodes = append(nodes, value)
+		}
+		if graph, ok := value["@graph"]; ok {
+			nodes = append(nodes, FindJsonLdEvents(graph)...)
+		}
+	}
+
+	return
+}
+
+func IsJsonLdEvent(jsonLdType interface{}) bool {
+	switch value := jsonLdType.(type) {
+	case string:
+		value = strings.TrimPrefix(value, "http://schema.org/")
+		value = strings.TrimPrefix(value, "https://schema.org/")
+		return jsonLdEventTypes[value]
+	case []interface{}:
+		for _, item := range value {
+			if IsJsonLdEvent(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func NewEventFromJsonLd(node map[string]interface{}) (event Event, err error) {
+	event.Name = html.UnescapeString(GetJsonLdString(node["name"]))
+
+	startDate := GetJsonLdString(node["startDate"])
+	event.StartTime, err = ParseJsonLdDate(startDate)
+	if err != nil {
+		return event, fmt.Errorf("Unable to parse startDate '%s' for '%s'", startDate, event.Name)
+	}
+
+	if location, ok := node["location"].(map[string]interface{}); ok {
+		event.Venue = html.UnescapeString(GetJsonLdString(location["name"]))
+	}
+
+	event.TicketUrl = GetJsonLdString(node["url"])
+	for _, offer := range GetJsonLdNodes(node["offers"]) {
+		if url := GetJsonLdString(offer["url"]); url != "" {
+			event.TicketUrl = url
+			break
+		}
+	}
+
+	for _, performer := range GetJsonLdNodes(node["performer"]) {
+		if name := html.UnescapeString(GetJsonLdString(performer["name"])); name != "" {
+			event.Performers = append(event.Performers, name)
+		}
+	}
+	if name, ok := node["performer"].(string); ok && name != "" {
+		event.Performers = append(event.Performers, html.UnescapeString(name))
+	}
+
+	return
+}
+
+func GetJsonLdNodes(value interface{}) (nodes []map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		nodes = append(nodes, v)
+	case []interface{}:
+		for _, item := range v {
+			if node, ok := item.(map[string]interface{}); ok {
+				nodes = append(nodes, node)
+			}
+		}
+	}
+	return
+}
+
+func GetJsonLdString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case []interface{}:
+		if len(v) > 0 {
+			return GetJsonLdString(v[0])
+		}
+	}
+	return ""
+}
+
+func ParseJsonLdDate(value string) (t time.Time, err error) {
+	for _, layout := range jsonLdDateLayouts {
+		if t, err = time.ParseInLocation(layout, value, time.Local); err == nil {
+			return
+		}
+	}
+	return
+}
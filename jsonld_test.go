@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJsonLdEventSource(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer server.Close()
+
+	window := Window{
+		From: time.Date(2017, 6, 1, 0, 0, 0, 0, time.Local),
+		To:   time.Date(2017, 6, 8, 0, 0, 0, 0, time.Local),
+	}
+
+	tests := []struct {
+		name     string
+		venue    Venue
+		expected []Event
+	}{
+		{
+			name:  "venue name from the page",
+			venue: Venue{Source: JsonLdSource, Url: server.URL + "/cafe-nine.html"},
+			expected: []Event{
+				{
+					Name:       "RYXNO & Friends",
+					StartTime:  time.Date(2017, 6, 2, 21, 0, 0, 0, time.FixedZone("", -4*60*60)),
+					Venue:      "Cafe Nine",
+					TicketUrl:  "https://example.com/tickets/ryxno",
+					Performers: []string{"RYXNO", "Dr. Beardface and the Spacemen"},
+				},
+				{
+					Name:       "Zombii",
+					StartTime:  time.Date(2017, 6, 4, 0, 0, 0, 0, time.Local),
+					Venue:      server.URL + "/cafe-nine.html",
+					Performers: []string{"Zombii"},
+				},
+			},
+		},
+		{
+			name:  "venue name from regions",
+			venue: Venue{Source: JsonLdSource, Name: "Cafe 9", Url: server.URL + "/cafe-nine.html"},
+			expected: []Event{
+				{
+					Name:       "RYXNO & Friends",
+					StartTime:  time.Date(2017, 6, 2, 21, 0, 0, 0, time.FixedZone("", -4*60*60)),
+					Venue:      "Cafe 9",
+					TicketUrl:  "https://example.com/tickets/ryxno",
+					Performers: []string{"RYXNO", "Dr. Beardface and the Spacemen"},
+				},
+				{
+					Name:       "Zombii",
+					StartTime:  time.Date(2017, 6, 4, 0, 0, 0, 0, time.Local),
+					Venue:      "Cafe 9",
+					Performers: []string{"Zombii"},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source, err := NewEventSources().NewEventSource(test.venue)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			events, err := source.GetUpcomingEvents(window)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(events) != len(test.expected) {
+				t.Fatalf("Expected %d events, got %+v", len(test.expected), events)
+			}
+			for i := range events {
+				if !events[i].StartTime.Equal(test.expected[i].StartTime) {
+					t.Errorf("Expected %v, got %v", test.expected[i].StartTime, events[i].StartTime)
+				}
+				events[i].StartTime = test.expected[i].StartTime
+				if !reflect.DeepEqual(events[i], test.expected[i]) {
+					t.Errorf("Expected %+v, got %+v", test.expected[i], events[i])
+				}
+			}
+		})
+	}
+}
+
+func TestJsonLdMissingPage(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer server.Close()
+
+	source := &JsonLdEventSource{venue: Venue{Source: JsonLdSource, Url: server.URL + "/missing.html"}}
+	if _, err := source.GetUpcomingEvents(Window{}); err == nil {
+		t.Errorf("Expected an error for a missing page")
+	}
+}
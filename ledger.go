@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/zmb3/spotify"
+)
+
+// LedgerEntry remembers when a track was added to a playlist and the show it
+// was added for, Spotify's added_at doesn't say which show that was.
+type LedgerEntry struct {
+	PlannedTrack
+	AddedAt time.Time
+}
+
+// Ledger keeps entries by playlist and then by track, along with the archive
+// playlists that have been created. A nil *Ledger is valid and records
+// nothing.
+type Ledger struct {
+	FileName  string `json:"-"`
+	Playlists map[spotify.ID]map[spotify.ID]*LedgerEntry
+	Archives  []Archive
+}
+
+type RetentionOptions struct {
+	Days      int
+	MaxTracks int
+}
+
+type RetentionPolicy struct {
+	Days      int
+	MaxTracks int
+	Ledger    *Ledger
+	Now       time.Time
+}
+
+func NewLedger() *Ledger {
+	return &Ledger{
+		Playlists: make(map[spotify.ID]map[spotify.ID]*LedgerEntry),
+		Archives:  make([]Archive, 0),
+	}
+}
+
+func LoadLedger(fileName string) (*Ledger, error) {
+	ledger := NewLedger()
+	ledger.FileName = fileName
+
+	file, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ledger, nil
+		}
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(file, &fields); err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %v", fileName, err)
+	}
+
+	// Ledgers used to be only the entries by playlist, from before archives
+	// were recorded in them too.
+	_, hasPlaylists := fields["Playlists"]
+	_, hasArchives := fields["Archives"]
+	if len(fields) > 0 && !hasPlaylists && !hasArchives {
+		if err := json.Unmarshal(file, &ledger.Playlists); err != nil {
+			return nil, fmt.Errorf("Unable to migrate %s: %v", fileName, err)
+		}
+
+		log.Printf("Migrated %s, %d playlists", fileName, len(ledger.Playlists))
+
+		return ledger, nil
+	}
+
+	if err := json.Unmarshal(file, ledger); err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %v", fileName, err)
+	}
+	if ledger.Playlists == nil {
+		ledger.Playlists = make(map[spotify.ID]map[spotify.ID]*LedgerEntry)
+	}
+
+	return ledger, nil
+}
+
+func (ledger *Ledger) Get(playlist spotify.ID, id spotify.ID) *LedgerEntry {
+	if ledger == nil {
+		return nil
+	}
+	return ledger.Playlists[playlist][id]
+}
+
+// Added records tracks as they're added. Tracks that were already there only
+// have their show updated, so retention counts from the latest one.
+func (ledger *Ledger) Added(playlist spotify.ID, tracks []PlannedTrack, now time.Time) {
+	if ledger == nil {
+		return
+	}
+
+	entries := ledger.Playlists[playlist]
+	if entries == nil {
+		entries = make(map[spotify.ID]*LedgerEntry)
+		ledger.Playlists[playlist] = entries
+	}
+
+	for _, track := range tracks {
+		addedAt := now
+		if entry, ok := entries[track.Id]; ok {
+			addedAt = entry.AddedAt
+		}
+		entries[track.Id] = &LedgerEntry{PlannedTrack: track, AddedAt: addedAt}
+	}
+}
+
+func (ledger *Ledger) Removed(playlist spotify.ID, tracks []PlannedTrack) {
+	if ledger == nil {
+		return
+	}
+
+	for _, track := range tracks {
+		delete(ledger.Playlists[playlist], track.Id)
+	}
+}
+
+// Forget drops a playlist's entries, for playlists without a retention
+// policy that nothing looks up.
+func (ledger *Ledger) Forget(playlist spotify.ID) {
+	if ledger == nil {
+		return
+	}
+
+	delete(ledger.Playlists, playlist)
+}
+
+func (ledger *Ledger) Save() error {
+	if ledger == nil || ledger.FileName == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(ledger, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(ledger.FileName, data, 0644)
+}
+
+func NewRetentionPolicy(options *RetentionOptions, ledger *Ledger, now time.Time) *RetentionPolicy {
+	if options == nil {
+		return nil
+	}
+
+	return &RetentionPolicy{
+		Days:      options.Days,
+		MaxTracks: options.MaxTracks,
+		Ledger:    ledger,
+		Now:       now,
+	}
+}
+
+// Retained is true for tracks added for a show less than Days ago. Tracks
+// the ledger doesn't know about aren't kept.
+func (policy *RetentionPolicy) Retained(entry *LedgerEntry) bool {
+	if entry == nil || entry.StartTime.IsZero() {
+		return false
+	}
+	return policy.Now.Before(entry.StartTime.AddDate(0, 0, policy.Days))
+}
+
+// Apply adds tracks from past shows that are still within the retention
+// period to this week's tracks and then, if there are more than MaxTracks,
+// evicts the tracks for the oldest shows.
+func (policy *RetentionPolicy) Apply(playlist spotify.ID, tracksBefore []spotify.PlaylistTrack, tracksAfter []PlannedTrack) []PlannedTrack {
+	after := NewEmptyTracksSet()
+	kept := make([]PlannedTrack, 0, len(tracksAfter))
+	for _, track := range tracksAfter {
+		if after.Contains(track.Id) {
+			continue
+		}
+		kept = append(kept, track)
+		after.Add(track.Id)
+		if track.LinkedFrom != "" {
+			after.Add(track.LinkedFrom)
+		}
+	}
+
+	for _, track := range tracksBefore {
+		if after.Contains(track.Track.ID) {
+			continue
+		}
+		entry := policy.Ledger.Get(playlist, track.Track.ID)
+		if policy.Retained(entry) {
+			kept = append(kept, entry.PlannedTrack)
+			after.Add(track.Track.ID)
+		}
+	}
+
+	if policy.MaxTracks <= 0 || len(kept) <= policy.MaxTracks {
+		return kept
+	}
+
+	oldest := make([]PlannedTrack, len(kept))
+	copy(oldest, kept)
+	SortByDate(oldest)
+
+	evicting := NewEmptyTracksSet()
+	for _, track := range oldest[:len(kept)-policy.MaxTracks] {
+		log.Printf("   Evicting %s - %s [%s]", track.Artist, track.Title, track.Event)
+		evicting.Add(track.Id)
+	}
+
+	capped := make([]PlannedTrack, 0, policy.MaxTracks)
+	for _, track := range kept {
+		if !evicting.Contains(track.Id) {
+			capped = append(capped, track)
+		}
+	}
+
+	return capped
+}
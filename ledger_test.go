@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zmb3/spotify"
+)
+
+func TestLedgerOnlyKeepsRetainedPlaylists(t *testing.T) {
+	show := time.Date(2017, 6, 2, 21, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		retained bool
+		expected int
+	}{
+		{"retained", true, 2},
+		{"not retained", false, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newTestCatalog()
+			z1, _ := fake.FindTrack("z1")
+			z2, _ := fake.FindTrack("z2")
+			newTestPlaylist(fake, "jlewalle", "weekly", "new haven weekly", *z1)
+
+			ledger := NewLedger()
+			ledger.Added("weekly", []PlannedTrack{NewPlannedTrack(*z1, "Zombii")}, show)
+
+			pp := &PlaylistPlan{
+				Title:      "new haven weekly",
+				User:       "jlewalle",
+				PlaylistId: "weekly",
+				Add:        []PlannedTrack{NewPlannedTrack(*z2, "Zombii")},
+				Keep:       []PlannedTrack{NewPlannedTrack(*z1, "Zombii")},
+				Retained:   test.retained,
+			}
+			if err := pp.Apply(fake, ledger); err != nil {
+				t.Fatal(err)
+			}
+
+			if actual := len(ledger.Playlists["weekly"]); actual != test.expected {
+				t.Errorf("Expected %d entries, got %d", test.expected, actual)
+			}
+			if test.retained && !ledger.Get("weekly", spotify.ID("z1")).AddedAt.Equal(show) {
+				t.Errorf("Expected kept tracks to keep when they were added")
+			}
+		})
+	}
+}
+
+func TestLoadLedger(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		entries  bool
+		archives int
+		err      bool
+	}{
+		{"current", "testdata/ledger.json", true, 1, false},
+		{"before archives", "testdata/ledger-unversioned.json", true, 0, false},
+		{"missing", "testdata/no-such-ledger.json", false, 0, false},
+		{"not a ledger", "testdata/events.json", false, 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ledger, err := LoadLedger(test.fileName)
+			if test.err {
+				if err == nil {
+					t.Errorf("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(ledger.Archives) != test.archives {
+				t.Errorf("Expected %d archives, got %d", test.archives, len(ledger.Archives))
+			}
+			if !test.entries {
+				return
+			}
+			if entry := ledger.Get("5Ia2yYbxVFkBcgpuVEiwQJ", "z1"); entry == nil || entry.Venue != "Cafe Nine" {
+				t.Errorf("Expected the entry for z1, got %v", entry)
+			}
+		})
+	}
+}
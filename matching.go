@@ -0,0 +1,307 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/zmb3/spotify"
+)
+
+const (
+	defaultMatchThreshold = 0.85
+	nearMissFloor         = 0.5
+)
+
+var diacritics = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c", 'đ': "d", 'ď': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ę': "e", 'ě': "e",
+	'ğ': "g", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'ı': "i",
+	'ł': "l", 'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o", 'œ': "oe",
+	'ř': "r", 'ś': "s", 'š': "s", 'ş': "s", 'ß': "ss", 'ť': "t",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u",
+	'ý': "y", 'ÿ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+var leadingArticles = []string{"the ", "a ", "an "}
+
+type NearMiss struct {
+	Guess     string
+	Venue     string
+	Candidate string
+	ArtistId  spotify.ID
+	Score     float64
+}
+
+type Ambiguity struct {
+	Guess      string
+	Venue      string
+	Candidates []string
+	Reason     string
+}
+
+type ArtistContext struct {
+	Genres        []string
+	MinPopularity int
+	MaxPopularity int
+	History       map[string]int
+}
+
+// ArtistChoice's Candidates is how many artists passed on their name alone,
+// Contextual is set when the region or venue picked between them.
+type ArtistChoice struct {
+	Artist     *spotify.FullArtist
+	Score      float64
+	Candidates int
+	Contextual bool
+	NearMiss   *NearMiss
+	Ambiguity  *Ambiguity
+}
+
+type scoredArtist struct {
+	artist   *spotify.FullArtist
+	score    float64
+	affinity int
+}
+
+type ArtistMatcher struct {
+	Threshold float64
+}
+
+func NewArtistMatcher(threshold float64) *ArtistMatcher {
+	return &ArtistMatcher{Threshold: threshold}
+}
+
+func FoldDiacritics(s string) string {
+	folded := make([]rune, 0, len(s))
+	for _, c := range s {
+		if replacement, ok := diacritics[c]; ok {
+			folded = append(folded, []rune(replacement)...)
+		} else {
+			folded = append(folded, c)
+		}
+	}
+	return string(folded)
+}
+
+func NormalizeForMatch(name string) string {
+	name = FoldDiacritics(strings.ToLower(name))
+	name = strings.Replace(name, "&", " and ", -1)
+	name = strings.Replace(name, "+", " and ", -1)
+
+	cleaned := make([]rune, 0, len(name))
+	for _, c := range name {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			cleaned = append(cleaned, c)
+		} else if c == '\'' || c == '.' {
+			continue
+		} else {
+			cleaned = append(cleaned, ' ')
+		}
+	}
+
+	name = strings.Join(strings.Fields(string(cleaned)), " ")
+	for _, article := range leadingArticles {
+		if strings.HasPrefix(name, article) && len(name) > len(article) {
+			name = name[len(article):]
+			break
+		}
+	}
+
+	return name
+}
+
+func EditDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(min(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+func NameSimilarity(a, b string) float64 {
+	ra := []rune(NormalizeForMatch(a))
+	rb := []rune(NormalizeForMatch(b))
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 0
+	}
+	return 1 - float64(EditDistance(ra, rb))/float64(longest)
+}
+
+// Score is name similarity, less a little for obscure, genre-less profiles
+// (often unrelated uploads) to push them below the threshold. Popularity is
+// left out so a popular artist with the wrong name can't pass, Choose uses it
+// to break ties between candidates that pass on their names.
+func (m *ArtistMatcher) Score(guess string, candidate *spotify.FullArtist) float64 {
+	score := NameSimilarity(guess, candidate.Name)
+	if strings.EqualFold(guess, candidate.Name) {
+		score = 1
+	}
+
+	if len(candidate.Genres) == 0 && candidate.Popularity == 0 {
+		score -= 0.05
+	}
+
+	if score > 1 {
+		score = 1
+	}
+	if score < 0 {
+		score = 0
+	}
+
+	return score
+}
+
+func (m *ArtistMatcher) Accepts(score float64) bool {
+	return score >= m.Threshold
+}
+
+func GenresMatch(a, b string) bool {
+	a = strings.ToLower(a)
+	b = strings.ToLower(b)
+	return strings.Contains(a, b) || strings.Contains(b, a)
+}
+
+func (ctx *ArtistContext) HasGenres() bool {
+	return len(ctx.Genres) > 0 || len(ctx.History) > 0
+}
+
+func (ctx *ArtistContext) InPopularityRange(artist *spotify.FullArtist) bool {
+	if artist.Popularity < ctx.MinPopularity {
+		return false
+	}
+	return ctx.MaxPopularity == 0 || artist.Popularity <= ctx.MaxPopularity
+}
+
+// Affinity counts the artist's genres the region expects twice as much as
+// genres that have been seen at the venue before.
+func (ctx *ArtistContext) Affinity(artist *spotify.FullArtist) (affinity int) {
+	for _, genre := range artist.Genres {
+		for _, expected := range ctx.Genres {
+			if GenresMatch(genre, expected) {
+				affinity += 2
+				break
+			}
+		}
+		if ctx.History[genre] > 0 {
+			affinity++
+		}
+	}
+	return
+}
+
+func (m *ArtistMatcher) Choose(guess string, candidates []spotify.FullArtist, ctx *ArtistContext) (choice ArtistChoice) {
+	var best *scoredArtist
+	accepted := make([]scoredArtist, 0)
+	for i := range candidates {
+		scored := scoredArtist{artist: &candidates[i], score: m.Score(guess, &candidates[i])}
+		if best == nil || scored.score > best.score {
+			best = &scored
+		}
+		if m.Accepts(scored.score) {
+			accepted = append(accepted, scored)
+		}
+	}
+
+	if len(accepted) == 0 {
+		if best != nil && best.score >= nearMissFloor {
+			choice.NearMiss = &NearMiss{
+				Guess:     guess,
+				Candidate: best.artist.Name,
+				ArtistId:  best.artist.ID,
+				Score:     best.score,
+			}
+		}
+		return
+	}
+
+	choice.Candidates = len(accepted)
+
+	if ctx != nil {
+		inRange := make([]scoredArtist, 0)
+		for _, scored := range accepted {
+			if ctx.InPopularityRange(scored.artist) {
+				inRange = append(inRange, scored)
+			}
+		}
+		if len(inRange) == 0 {
+			choice.Ambiguity = NewAmbiguity(guess, accepted, fmt.Sprintf("popularity outside %d-%d", ctx.MinPopularity, ctx.MaxPopularity))
+			return
+		}
+		choice.Contextual = len(inRange) != len(accepted)
+		accepted = inRange
+	}
+
+	if len(accepted) == 1 || ctx == nil || !ctx.HasGenres() {
+		// Without genres to go on keep the best score, then the most
+		// popular, in Spotify's order.
+		top := accepted[0]
+		for _, scored := range accepted[1:] {
+			if scored.score > top.score || (scored.score == top.score && scored.artist.Popularity > top.artist.Popularity) {
+				top = scored
+			}
+		}
+		choice.Artist = top.artist
+		choice.Score = top.score
+		return
+	}
+
+	for i := range accepted {
+		accepted[i].affinity = ctx.Affinity(accepted[i].artist)
+	}
+
+	sort.SliceStable(accepted, func(i, j int) bool {
+		if accepted[i].affinity != accepted[j].affinity {
+			return accepted[i].affinity > accepted[j].affinity
+		}
+		if accepted[i].score != accepted[j].score {
+			return accepted[i].score > accepted[j].score
+		}
+		return accepted[i].artist.Popularity > accepted[j].artist.Popularity
+	})
+
+	first, second := accepted[0], accepted[1]
+	if first.affinity > second.affinity || first.score-second.score >= 0.1 {
+		choice.Artist = first.artist
+		choice.Score = first.score
+		choice.Contextual = true
+		return
+	}
+
+	reason := fmt.Sprintf("%d candidates, none match the expected genres", len(accepted))
+	if first.affinity > 0 {
+		reason = fmt.Sprintf("%d candidates tied on genre", len(accepted))
+	}
+	choice.Ambiguity = NewAmbiguity(guess, accepted, reason)
+
+	return
+}
+
+func NewAmbiguity(guess string, candidates []scoredArtist, reason string) *Ambiguity {
+	ambiguity := &Ambiguity{Guess: guess, Reason: reason}
+	for _, scored := range candidates {
+		ambiguity.Candidates = append(ambiguity.Candidates, fmt.Sprintf("%s (%s, %d, %s)", scored.artist.Name, scored.artist.ID, scored.artist.Popularity, strings.Join(scored.artist.Genres, "/")))
+	}
+	return ambiguity
+}
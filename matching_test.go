@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/zmb3/spotify"
+)
+
+func newTestArtist(id string, name string, popularity int, genres ...string) spotify.FullArtist {
+	return spotify.FullArtist{
+		SimpleArtist: spotify.SimpleArtist{ID: spotify.ID(id), Name: name},
+		Popularity:   popularity,
+		Genres:       genres,
+	}
+}
+
+func TestArtistMatcherChoose(t *testing.T) {
+	tests := []struct {
+		name       string
+		guess      string
+		candidates []spotify.FullArtist
+		ctx        *ArtistContext
+		expected   spotify.ID
+		nearMiss   bool
+		ambiguous  bool
+	}{
+		{
+			name:       "exact name",
+			guess:      "Zombii",
+			candidates: []spotify.FullArtist{newTestArtist("zombii", "Zombii", 20, "punk")},
+			expected:   "zombii",
+		},
+		{
+			name:       "leading article and accents",
+			guess:      "Spacemen",
+			candidates: []spotify.FullArtist{newTestArtist("spacemen", "The Spacémen", 5, "indie")},
+			expected:   "spacemen",
+		},
+		{
+			name:       "popularity doesn't pass a wrong name",
+			guess:      "Zombi",
+			candidates: []spotify.FullArtist{newTestArtist("zombii", "Zombii", 100, "punk")},
+			nearMiss:   true,
+		},
+		{
+			name:  "popularity breaks ties",
+			guess: "Ryxno",
+			candidates: []spotify.FullArtist{
+				newTestArtist("obscure", "Ryxno", 3, "noise"),
+				newTestArtist("popular", "RYXNO", 60, "indie"),
+			},
+			expected: "popular",
+		},
+		{
+			name:  "name beats popularity",
+			guess: "Dr. Beardface",
+			candidates: []spotify.FullArtist{
+				newTestArtist("close", "Dr Beardfaces", 90, "rock"),
+				newTestArtist("exact", "Dr. Beardface", 10, "rock"),
+			},
+			expected: "exact",
+		},
+		{
+			name:  "genres pick between identical names",
+			guess: "Residents",
+			candidates: []spotify.FullArtist{
+				newTestArtist("rap", "Residents", 50, "rap"),
+				newTestArtist("punk", "Residents", 10, "punk"),
+			},
+			ctx:      &ArtistContext{Genres: []string{"punk"}},
+			expected: "punk",
+		},
+		{
+			name:  "popularity doesn't settle a genre tie",
+			guess: "Residents",
+			candidates: []spotify.FullArtist{
+				newTestArtist("quiet", "Residents", 10, "punk"),
+				newTestArtist("loud", "Residents", 50, "punk"),
+			},
+			ctx:       &ArtistContext{Genres: []string{"punk"}},
+			ambiguous: true,
+		},
+	}
+
+	matcher := NewArtistMatcher(defaultMatchThreshold)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			choice := matcher.Choose(test.guess, test.candidates, test.ctx)
+
+			if test.nearMiss != (choice.NearMiss != nil) {
+				t.Errorf("Expected near miss %v, got %v", test.nearMiss, choice.NearMiss)
+			}
+			if test.ambiguous != (choice.Ambiguity != nil) {
+				t.Errorf("Expected ambiguity %v, got %v", test.ambiguous, choice.Ambiguity)
+			}
+
+			var actual spotify.ID
+			if choice.Artist != nil {
+				actual = choice.Artist.ID
+			}
+			if actual != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"github.com/zmb3/spotify"
+)
+
+// MusicService is the part of *spotify.Client the playlist code uses, so a
+// FakeMusicService can stand in for it.
+type MusicService interface {
+	CurrentUser() (*spotify.PrivateUser, error)
+	SearchOpt(query string, t spotify.SearchType, opt *spotify.Options) (*spotify.SearchResult, error)
+	GetArtist(id spotify.ID) (*spotify.FullArtist, error)
+	GetArtistsTopTracks(artistID spotify.ID, country string) ([]spotify.FullTrack, error)
+	GetArtistAlbumsOpt(artistID spotify.ID, options *spotify.Options, t *spotify.AlbumType) (*spotify.SimpleAlbumPage, error)
+	GetAlbumTracksOpt(id spotify.ID, limit, offset int) (*spotify.SimpleTrackPage, error)
+	GetPlaylistsForUserOpt(userID string, opt *spotify.Options) (*spotify.SimplePlaylistPage, error)
+	CreatePlaylistForUser(userID, playlistName, description string, public bool) (*spotify.FullPlaylist, error)
+	GetPlaylistTracksOpt(playlistID spotify.ID, opt *spotify.Options, fields string) (*spotify.PlaylistTrackPage, error)
+	AddTracksToPlaylist(playlistID spotify.ID, trackIDs ...spotify.ID) (string, error)
+	RemoveTracksFromPlaylist(playlistID spotify.ID, trackIDs ...spotify.ID) (string, error)
+	ReorderPlaylistTracks(playlistID spotify.ID, opt spotify.PlaylistReorderOptions) (string, error)
+	UnfollowPlaylist(owner, playlist spotify.ID) error
+	ChangePlaylistDescription(playlistID spotify.ID, newDescription string) error
+	ChangePlaylistAccess(playlistID spotify.ID, public bool) error
+}
+
+var _ MusicService = &spotify.Client{}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"text/template"
+	"time"
+
+	"github.com/zmb3/spotify"
+)
+
+const (
+	SlackNotifierType   = "slack"
+	DiscordNotifierType = "discord"
+	WebhookNotifierType = "webhook"
+)
+
+const (
+	notifyTimeout        = 30 * time.Second
+	maximumDiscordLength = 2000
+)
+
+const defaultChatTemplate = `weekly-playlist {{.Started.Format "Jan 2"}}{{if .DryRun}} (dry run){{end}}
+{{range .Regions}}{{.Region}}: {{with .Counts}}{{.Events}} events, {{.Unresolved}} unresolved, {{.Added}} added, {{.Removed}} removed{{end}}{{if .Failed}} FAILED{{end}}
+{{range .Errors}}    {{.}}
+{{end}}{{end}}{{range .Errors}}{{.}}
+{{end}}`
+
+// NotifierConfig is a webhook the run summary is posted to. Template is a
+// text/template file given the RunReport, for chat webhooks it's the message
+// and for a generic webhook it's the whole JSON body, which otherwise is a
+// ReportSummary. FailuresOnly only posts when a region failed.
+type NotifierConfig struct {
+	Type         string
+	Url          string
+	Template     string
+	FailuresOnly bool
+}
+
+type Notifier interface {
+	Notify(report *RunReport) error
+}
+
+// ChatNotifier posts the rendered template as a Slack or Discord message,
+// Field is where each of them expects the text.
+type ChatNotifier struct {
+	Name      string
+	Url       string
+	Field     string
+	MaxLength int
+	Template  *template.Template
+}
+
+type WebhookNotifier struct {
+	Url      string
+	Template *template.Template
+}
+
+type FailuresOnlyNotifier struct {
+	Notifier Notifier
+}
+
+// ReportSummary is the JSON a generic webhook gets, the RunReport without the
+// guesses and tracks.
+type ReportSummary struct {
+	Started time.Time
+	DryRun  bool
+	Failed  bool
+	Counts  ReportCounts
+	Regions []RegionSummary
+	Errors  []string
+}
+
+type RegionSummary struct {
+	Region     string
+	Playlist   string
+	PlaylistId spotify.ID
+	Failed     bool
+	Counts     ReportCounts
+	Unresolved []string
+	Errors     []string
+}
+
+func NewNotifier(config NotifierConfig) (Notifier, error) {
+	if config.Url == "" {
+		return nil, fmt.Errorf("%s notifier has no Url", config.Type)
+	}
+
+	var notifier Notifier
+	switch config.Type {
+	case SlackNotifierType, DiscordNotifierType:
+		tmpl, err := LoadNotifierTemplate(config.Template, defaultChatTemplate)
+		if err != nil {
+			return nil, err
+		}
+		if config.Type == SlackNotifierType {
+			notifier = &ChatNotifier{Name: config.Type, Url: config.Url, Field: "text", Template: tmpl}
+		} else {
+			notifier = &ChatNotifier{Name: config.Type, Url: config.Url, Field: "content", MaxLength: maximumDiscordLength, Template: tmpl}
+		}
+	case WebhookNotifierType:
+		tmpl, err := LoadNotifierTemplate(config.Template, "")
+		if err != nil {
+			return nil, err
+		}
+		notifier = &WebhookNotifier{Url: config.Url, Template: tmpl}
+	default:
+		return nil, fmt.Errorf("Unknown notifier '%s', expected %s, %s or %s", config.Type, SlackNotifierType, DiscordNotifierType, WebhookNotifierType)
+	}
+
+	if config.FailuresOnly {
+		notifier = &FailuresOnlyNotifier{notifier}
+	}
+
+	return notifier, nil
+}
+
+func NewNotifiers(configs []NotifierConfig) ([]Notifier, error) {
+	notifiers := make([]Notifier, 0)
+	for _, config := range configs {
+		notifier, err := NewNotifier(config)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, notifier)
+	}
+	return notifiers, nil
+}
+
+// LoadNotifierTemplate parses fileName, or text when there's no file. Without
+// either there's no template.
+func LoadNotifierTemplate(fileName string, text string) (*template.Template, error) {
+	if fileName != "" {
+		file, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+		text = string(file)
+	}
+	if text == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New("notifier").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse notifier template %s: %v", fileName, err)
+	}
+	return tmpl, nil
+}
+
+func Notify(notifiers []Notifier, report *RunReport) {
+	for _, notifier := range notifiers {
+		if err := notifier.Notify(report); err != nil {
+			log.Printf("ERROR: Unable to notify: %v", err)
+		}
+	}
+}
+
+func (n *ChatNotifier) Notify(report *RunReport) error {
+	var buffer bytes.Buffer
+	if err := n.Template.Execute(&buffer, report); err != nil {
+		return fmt.Errorf("Unable to render %s message: %v", n.Name, err)
+	}
+
+	text := buffer.String()
+	if runes := []rune(text); n.MaxLength > 0 && len(runes) > n.MaxLength {
+		text = string(runes[:n.MaxLength-3]) + "..."
+	}
+
+	body, err := json.Marshal(map[string]string{n.Field: text})
+	if err != nil {
+		return err
+	}
+
+	return PostJSON(n.Name, n.Url, body)
+}
+
+func (n *WebhookNotifier) Notify(report *RunReport) error {
+	if n.Template == nil {
+		body, err := json.Marshal(NewReportSummary(report))
+		if err != nil {
+			return err
+		}
+		return PostJSON("webhook", n.Url, body)
+	}
+
+	var buffer bytes.Buffer
+	if err := n.Template.Execute(&buffer, report); err != nil {
+		return fmt.Errorf("Unable to render webhook body: %v", err)
+	}
+
+	return PostJSON("webhook", n.Url, buffer.Bytes())
+}
+
+func (n *FailuresOnlyNotifier) Notify(report *RunReport) error {
+	if !report.Failed() {
+		return nil
+	}
+	return n.Notifier.Notify(report)
+}
+
+// PostJSON leaves the url out of errors, webhook urls are their own
+// credentials.
+func PostJSON(name string, url string, body []byte) error {
+	client := &http.Client{Timeout: notifyTimeout}
+	r, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		if urlErr, ok := err.(*neturl.Error); ok {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("Error posting to %s webhook: %v", name, err)
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode < 200 || r.StatusCode >= 300 {
+		return fmt.Errorf("Error posting to %s webhook: %s", name, r.Status)
+	}
+
+	return nil
+}
+
+func NewReportSummary(report *RunReport) *ReportSummary {
+	summary := &ReportSummary{
+		Started: report.Started,
+		DryRun:  report.DryRun,
+		Failed:  report.Failed(),
+		Counts:  report.Counts(),
+		Regions: make([]RegionSummary, 0),
+		Errors:  report.Errors,
+	}
+	if summary.Errors == nil {
+		summary.Errors = make([]string, 0)
+	}
+
+	for _, rr := range report.Regions {
+		unresolved := make([]string, 0)
+		for _, resolved := range rr.Unresolved() {
+			unresolved = append(unresolved, resolved.Event.Name)
+		}
+
+		errors := rr.Errors
+		if errors == nil {
+			errors = make([]string, 0)
+		}
+
+		summary.Regions = append(summary.Regions, RegionSummary{
+			Region:     rr.Region,
+			Playlist:   rr.Playlist,
+			PlaylistId: rr.PlaylistId,
+			Failed:     rr.Failed(),
+			Counts:     rr.Counts(),
+			Unresolved: unresolved,
+			Errors:     errors,
+		})
+	}
+
+	return summary
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotifyRunErrors(t *testing.T) {
+	posted := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make(map[string]string)
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Unable to parse message: %v", err)
+		}
+		posted = append(posted, body["text"])
+	}))
+	defer server.Close()
+
+	notifier, err := NewNotifier(NotifierConfig{Type: SlackNotifierType, Url: server.URL, FailuresOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := NewRunReport(time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC), false)
+	if err := notifier.Notify(report); err != nil {
+		t.Fatal(err)
+	}
+	if len(posted) != 0 {
+		t.Fatalf("Expected nothing posted without failures, got %v", posted)
+	}
+
+	report.AddError("Unable to update eclectic: 503 Service Unavailable")
+	if !report.Failed() || !report.HasErrors() {
+		t.Errorf("Expected the run to have failed")
+	}
+	if err := notifier.Notify(report); err != nil {
+		t.Fatal(err)
+	}
+	if len(posted) != 1 || !strings.Contains(posted[0], "Unable to update eclectic") {
+		t.Errorf("Expected the run's errors to be posted, got %v", posted)
+	}
+}
+
+func TestHasErrors(t *testing.T) {
+	report := NewRunReport(time.Now(), false)
+	report.Add(&RegionReport{Region: "new haven"})
+	if !report.Failed() || report.HasErrors() {
+		t.Errorf("Expected a region without tracks to fail without errors")
+	}
+
+	report.Add(&RegionReport{Region: "los angeles", Errors: []string{"Unable to get playlist: 500"}})
+	if !report.HasErrors() {
+		t.Errorf("Expected a region's errors to be the run's")
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	DateOrder       = "date"
+	VenueOrder      = "venue"
+	InterleaveOrder = "interleave"
+)
+
+func ValidateOrder(order string) error {
+	switch order {
+	case "", DateOrder, VenueOrder, InterleaveOrder:
+		return nil
+	}
+	return fmt.Errorf("Unknown playlist order '%s'", order)
+}
+
+// OrderTracks returns the tracks in the order the playlist should have them.
+// Ties keep the order the tracks were found in, venue then event.
+func OrderTracks(tracks []PlannedTrack, order string) ([]PlannedTrack, error) {
+	if err := ValidateOrder(order); err != nil {
+		return nil, err
+	}
+
+	ordered := make([]PlannedTrack, len(tracks))
+	copy(ordered, tracks)
+
+	switch order {
+	case DateOrder:
+		SortByDate(ordered)
+	case VenueOrder:
+		venues := make(map[string]int)
+		for _, track := range ordered {
+			if _, ok := venues[track.Venue]; !ok {
+				venues[track.Venue] = len(venues)
+			}
+		}
+		sort.SliceStable(ordered, func(i, j int) bool {
+			if venues[ordered[i].Venue] != venues[ordered[j].Venue] {
+				return venues[ordered[i].Venue] < venues[ordered[j].Venue]
+			}
+			return ordered[i].StartTime.Before(ordered[j].StartTime)
+		})
+	case InterleaveOrder:
+		SortByDate(ordered)
+		ordered = Interleave(ordered)
+	}
+
+	return ordered, nil
+}
+
+func SortByDate(tracks []PlannedTrack) {
+	sort.SliceStable(tracks, func(i, j int) bool {
+		return tracks[i].StartTime.Before(tracks[j].StartTime)
+	})
+}
+
+// Interleave keeps tracks close to their original order while making sure no
+// artist plays twice in a row. An artist is only moved ahead of others when
+// leaving them for later would leave no way of spacing their tracks out.
+func Interleave(tracks []PlannedTrack) []PlannedTrack {
+	remaining := make([]PlannedTrack, len(tracks))
+	copy(remaining, tracks)
+
+	counts := make(map[string]int)
+	for _, track := range remaining {
+		counts[track.Artist]++
+	}
+
+	interleaved := make([]PlannedTrack, 0, len(tracks))
+	previous := ""
+	for len(remaining) > 0 {
+		pick := -1
+
+		for i, track := range remaining {
+			if track.Artist != previous && 2*counts[track.Artist] > len(remaining) {
+				pick = i
+				break
+			}
+		}
+
+		if pick < 0 {
+			for i, track := range remaining {
+				if track.Artist != previous {
+					pick = i
+					break
+				}
+			}
+		}
+
+		if pick < 0 {
+			pick = 0
+		}
+
+		track := remaining[pick]
+		remaining = append(remaining[:pick], remaining[pick+1:]...)
+		counts[track.Artist]--
+		interleaved = append(interleaved, track)
+		previous = track.Artist
+	}
+
+	return interleaved
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zmb3/spotify"
+)
+
+func newTestPlannedTrack(id string, artist string, venue string, day int) PlannedTrack {
+	return PlannedTrack{
+		Id:        spotify.ID(id),
+		Artist:    artist,
+		Title:     id,
+		Venue:     venue,
+		StartTime: time.Date(2017, 6, day, 20, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestOrderTracks(t *testing.T) {
+	tests := []struct {
+		name     string
+		order    string
+		tracks   []PlannedTrack
+		expected []spotify.ID
+	}{
+		{
+			name:     "empty",
+			order:    DateOrder,
+			tracks:   []PlannedTrack{},
+			expected: nil,
+		},
+		{
+			name:  "appended as found",
+			order: "",
+			tracks: []PlannedTrack{
+				newTestPlannedTrack("late", "Zombii", "Cafe Nine", 3),
+				newTestPlannedTrack("soon", "RYXNO", "Cafe Nine", 1),
+			},
+			expected: []spotify.ID{"late", "soon"},
+		},
+		{
+			name:  "date ties keep the order they were found in",
+			order: DateOrder,
+			tracks: []PlannedTrack{
+				newTestPlannedTrack("z1", "Zombii", "Cafe Nine", 2),
+				newTestPlannedTrack("r1", "RYXNO", "The Space", 1),
+				newTestPlannedTrack("b1", "Beardface", "Cafe Nine", 1),
+			},
+			expected: []spotify.ID{"r1", "b1", "z1"},
+		},
+		{
+			name:  "venues in the order they were found, by date within each",
+			order: VenueOrder,
+			tracks: []PlannedTrack{
+				newTestPlannedTrack("z1", "Zombii", "Cafe Nine", 3),
+				newTestPlannedTrack("r1", "RYXNO", "The Space", 1),
+				newTestPlannedTrack("b1", "Beardface", "Cafe Nine", 1),
+				newTestPlannedTrack("b2", "Beardface", "Cafe Nine", 1),
+				newTestPlannedTrack("s1", "Spacemen", "The Space", 2),
+			},
+			expected: []spotify.ID{"b1", "b2", "z1", "r1", "s1"},
+		},
+		{
+			name:  "one venue",
+			order: VenueOrder,
+			tracks: []PlannedTrack{
+				newTestPlannedTrack("z1", "Zombii", "Cafe Nine", 3),
+				newTestPlannedTrack("r1", "RYXNO", "Cafe Nine", 1),
+			},
+			expected: []spotify.ID{"r1", "z1"},
+		},
+		{
+			name:  "interleave spaces artists out",
+			order: InterleaveOrder,
+			tracks: []PlannedTrack{
+				newTestPlannedTrack("z1", "Zombii", "Cafe Nine", 1),
+				newTestPlannedTrack("z2", "Zombii", "Cafe Nine", 1),
+				newTestPlannedTrack("z3", "Zombii", "Cafe Nine", 1),
+				newTestPlannedTrack("r1", "RYXNO", "The Space", 2),
+				newTestPlannedTrack("r2", "RYXNO", "The Space", 2),
+			},
+			expected: []spotify.ID{"z1", "r1", "z2", "r2", "z3"},
+		},
+		{
+			name:  "interleave with too many of one artist",
+			order: InterleaveOrder,
+			tracks: []PlannedTrack{
+				newTestPlannedTrack("z1", "Zombii", "Cafe Nine", 1),
+				newTestPlannedTrack("z2", "Zombii", "Cafe Nine", 1),
+				newTestPlannedTrack("z3", "Zombii", "Cafe Nine", 1),
+				newTestPlannedTrack("r1", "RYXNO", "The Space", 2),
+			},
+			expected: []spotify.ID{"z1", "r1", "z2", "z3"},
+		},
+		{
+			name:  "interleave keeps date order when it can",
+			order: InterleaveOrder,
+			tracks: []PlannedTrack{
+				newTestPlannedTrack("b1", "Beardface", "Cafe Nine", 3),
+				newTestPlannedTrack("z1", "Zombii", "Cafe Nine", 1),
+				newTestPlannedTrack("r1", "RYXNO", "The Space", 2),
+				newTestPlannedTrack("z2", "Zombii", "Cafe Nine", 1),
+			},
+			expected: []spotify.ID{"z1", "r1", "z2", "b1"},
+		},
+		{
+			name:  "interleave one artist",
+			order: InterleaveOrder,
+			tracks: []PlannedTrack{
+				newTestPlannedTrack("z2", "Zombii", "Cafe Nine", 2),
+				newTestPlannedTrack("z1", "Zombii", "Cafe Nine", 1),
+			},
+			expected: []spotify.ID{"z1", "z2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ordered, err := OrderTracks(test.tracks, test.order)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := GetPlannedTrackIds(ordered); !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+
+	if _, err := OrderTracks(nil, "shuffle"); err == nil {
+		t.Errorf("Expected an unknown order to fail")
+	}
+}
+
+type reorderCountingService struct {
+	*FakeMusicService
+	reorders int
+}
+
+func (s *reorderCountingService) ReorderPlaylistTracks(playlistID spotify.ID, opt spotify.PlaylistReorderOptions) (string, error) {
+	s.reorders++
+	return s.FakeMusicService.ReorderPlaylistTracks(playlistID, opt)
+}
+
+func TestReorderPlaylistTracks(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  []spotify.ID
+		order    []spotify.ID
+		expected []spotify.ID
+		moves    int
+	}{
+		{
+			name:     "in order",
+			current:  []spotify.ID{"a", "b", "c"},
+			order:    []spotify.ID{"a", "b", "c"},
+			expected: []spotify.ID{"a", "b", "c"},
+			moves:    0,
+		},
+		{
+			name:     "empty",
+			current:  []spotify.ID{},
+			order:    []spotify.ID{},
+			expected: []spotify.ID{},
+			moves:    0,
+		},
+		{
+			name:     "last to first",
+			current:  []spotify.ID{"b", "c", "d", "a"},
+			order:    []spotify.ID{"a", "b", "c", "d"},
+			expected: []spotify.ID{"a", "b", "c", "d"},
+			moves:    1,
+		},
+		{
+			name:     "first to last",
+			current:  []spotify.ID{"d", "a", "b", "c"},
+			order:    []spotify.ID{"a", "b", "c", "d"},
+			expected: []spotify.ID{"a", "b", "c", "d"},
+			moves:    1,
+		},
+		{
+			name:     "reversed",
+			current:  []spotify.ID{"e", "d", "c", "b", "a"},
+			order:    []spotify.ID{"a", "b", "c", "d", "e"},
+			expected: []spotify.ID{"a", "b", "c", "d", "e"},
+			moves:    4,
+		},
+		{
+			name:     "two swapped pairs",
+			current:  []spotify.ID{"b", "a", "d", "c"},
+			order:    []spotify.ID{"a", "b", "c", "d"},
+			expected: []spotify.ID{"a", "b", "c", "d"},
+			moves:    2,
+		},
+		{
+			name:     "duplicates",
+			current:  []spotify.ID{"a", "b", "a"},
+			order:    []spotify.ID{"a", "a", "b"},
+			expected: []spotify.ID{"a", "a", "b"},
+			moves:    1,
+		},
+		{
+			name:     "tracks missing from order go last",
+			current:  []spotify.ID{"x", "b", "a"},
+			order:    []spotify.ID{"a", "b", "gone"},
+			expected: []spotify.ID{"a", "b", "x"},
+			moves:    2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := NewFakeMusicService("jlewalle")
+			artist := newTestArtist("zombii", "Zombii", 20)
+			tracks := make([]spotify.FullTrack, 0)
+			for _, id := range test.current {
+				tracks = append(tracks, newTestTrack(string(id), string(id), artist))
+			}
+			pl := newTestPlaylist(fake, "jlewalle", "weekly", "new haven weekly", tracks...)
+
+			if moves := PlanReorder(test.current, test.order); len(moves) != test.moves {
+				t.Errorf("Expected %d moves, got %d: %+v", test.moves, len(moves), moves)
+			}
+
+			service := &reorderCountingService{FakeMusicService: fake}
+			if err := ReorderPlaylistTracks(service, "weekly", test.order); err != nil {
+				t.Fatal(err)
+			}
+			if actual := getTestPlaylistTrackIds(pl); !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, actual)
+			}
+			if service.reorders != test.moves {
+				t.Errorf("Expected %d reorders, got %d", test.moves, service.reorders)
+			}
+		})
+	}
+}
+
+func TestPlanReorderEveryPermutation(t *testing.T) {
+	order := []spotify.ID{"a", "b", "c", "d", "e", "f"}
+
+	var permute func(current []spotify.ID, k int)
+	permute = func(current []spotify.ID, k int) {
+		if k == len(current) {
+			fake := NewFakeMusicService("jlewalle")
+			artist := newTestArtist("zombii", "Zombii", 20)
+			pl := newTestPlaylist(fake, "jlewalle", "weekly", "new haven weekly")
+			positions := make([]int, 0)
+			for _, id := range current {
+				pl.Tracks = append(pl.Tracks, spotify.PlaylistTrack{Track: newTestTrack(string(id), string(id), artist)})
+				positions = append(positions, int(id[0]-'a'))
+			}
+
+			kept := 0
+			for _, keep := range LongestIncreasing(positions) {
+				if keep {
+					kept++
+				}
+			}
+
+			moves := PlanReorder(current, order)
+			for _, move := range moves {
+				if _, err := fake.ReorderPlaylistTracks("weekly", move); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if actual := getTestPlaylistTrackIds(pl); !reflect.DeepEqual(actual, order) {
+				t.Errorf("Expected %v to become %v, got %v", current, order, actual)
+			}
+			if len(moves) != len(order)-kept {
+				t.Errorf("Expected %v to take %d moves, got %d", current, len(order)-kept, len(moves))
+			}
+			return
+		}
+		for i := k; i < len(current); i++ {
+			current[k], current[i] = current[i], current[k]
+			permute(current, k+1)
+			current[k], current[i] = current[i], current[k]
+		}
+	}
+
+	permute([]spotify.ID{"a", "b", "c", "d", "e", "f"}, 0)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/zmb3/spotify"
+)
+
+type ArtistOverride struct {
+	Match    string
+	Venue    string
+	ArtistId spotify.ID
+	Skip     bool
+	Aliases  []string
+}
+
+type ArtistOverrides struct {
+	Overrides []ArtistOverride
+}
+
+func LoadArtistOverrides(fileName string) (*ArtistOverrides, error) {
+	overrides := &ArtistOverrides{}
+
+	file, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return overrides, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(file, &overrides.Overrides); err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %v", fileName, err)
+	}
+
+	log.Printf("Loaded %d artist overrides from %s", len(overrides.Overrides), fileName)
+
+	return overrides, nil
+}
+
+// Find prefers a rule scoped to the venue, by its Id in the regions file or
+// the name on the event, over one that applies everywhere.
+func (ao *ArtistOverrides) Find(name string, venueKey string, venue string) *ArtistOverride {
+	if ao == nil {
+		return nil
+	}
+
+	key := NormalizeArtistName(name)
+	venues := []string{NormalizeArtistName(venueKey), NormalizeArtistName(venue)}
+
+	var global *ArtistOverride
+	for i := range ao.Overrides {
+		override := &ao.Overrides[i]
+		if NormalizeArtistName(override.Match) != key {
+			continue
+		}
+		if override.Venue == "" {
+			if global == nil {
+				global = override
+			}
+		} else if scoped := NormalizeArtistName(override.Venue); scoped == venues[0] || scoped == venues[1] {
+			return override
+		}
+	}
+
+	return global
+}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestArtistOverridesFind(t *testing.T) {
+	overrides := &ArtistOverrides{
+		Overrides: []ArtistOverride{
+			{Match: "Residents", ArtistId: "global"},
+			{Match: "Residents", Venue: "the_echo", ArtistId: "by-id"},
+			{Match: "Residents", Venue: "Cafe Nine", ArtistId: "by-name"},
+			{Match: "Trivia Night", Skip: true},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		guess    string
+		venueKey string
+		venue    string
+		expected string
+	}{
+		{"venue id", "Residents", "the_echo", "The Echo", "by-id"},
+		{"venue name", "Residents", "cafenineNH", "Cafe Nine", "by-name"},
+		{"another venue", "Residents", "toads", "Toad's Place", "global"},
+		{"normalized guess", "  residents ", "", "", "global"},
+		{"skip", "Trivia Night", "the_echo", "The Echo", ""},
+		{"no rule", "Zombii", "the_echo", "The Echo", "none"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			override := overrides.Find(test.guess, test.venueKey, test.venue)
+
+			actual := "none"
+			if override != nil {
+				actual = string(override.ArtistId)
+			}
+			if actual != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, actual)
+			}
+		})
+	}
+}
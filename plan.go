@@ -0,0 +1,318 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/zmb3/spotify"
+)
+
+const maximumDescriptionLength = 300
+
+// PlannedTrack's LinkedFrom is the track Spotify relinked to Id because the
+// original isn't playable in the region's market.
+type PlannedTrack struct {
+	Id         spotify.ID
+	LinkedFrom spotify.ID `json:",omitempty"`
+	Artist     string
+	Title      string
+	Event      string `json:",omitempty"`
+	Venue      string `json:",omitempty"`
+	StartTime  time.Time
+}
+
+// PlaylistPlan's Order, when there is one, is every track the playlist should
+// end up with in the order they should be in. Keep are the tracks that stay,
+// their shows are updated in the ledger when the playlist is Retained, other
+// playlists aren't kept in the ledger. Description and Public are set on the
+// playlist every time, unless they're empty.
+type PlaylistPlan struct {
+	Title       string
+	User        string
+	Description string     `json:",omitempty"`
+	Public      *bool      `json:",omitempty"`
+	PlaylistId  spotify.ID `json:",omitempty"`
+	Add         []PlannedTrack
+	Remove      []PlannedTrack
+	Keep        []PlannedTrack `json:",omitempty"`
+	Order       []spotify.ID   `json:",omitempty"`
+	Retained    bool           `json:",omitempty"`
+	Archive     *Archive       `json:",omitempty"`
+}
+
+type Plan struct {
+	Created   time.Time
+	Playlists []*PlaylistPlan
+}
+
+func NewPlannedTrack(track spotify.FullTrack, event string) PlannedTrack {
+	artist := ""
+	if len(track.Artists) > 0 {
+		artist = track.Artists[0].Name
+	}
+	planned := PlannedTrack{
+		Id:     track.ID,
+		Artist: artist,
+		Title:  track.Name,
+		Event:  event,
+	}
+	if track.LinkedFrom != nil {
+		planned.LinkedFrom = track.LinkedFrom.ID
+	}
+	return planned
+}
+
+func NewPlannedTrackForEvent(track spotify.FullTrack, event Event) PlannedTrack {
+	planned := NewPlannedTrack(track, DescribeEvent(event))
+	planned.Venue = event.Venue
+	planned.StartTime = event.StartTime
+	return planned
+}
+
+func DescribeEvent(event Event) string {
+	return fmt.Sprintf("%s (%s, %s)", event.Name, event.Venue, event.StartTime.Format("Mon Jan 2"))
+}
+
+// DescribeRegionPlaylist lists as many venues as fit in Spotify's 300
+// character limit for descriptions.
+func DescribeRegionPlaylist(venues []string, window Window) string {
+	dates := fmt.Sprintf("%s to %s", window.From.Format("Jan 2"), window.To.Add(-time.Nanosecond).Format("Jan 2, 2006"))
+	describe := func(listed []string, more int) string {
+		names := strings.Join(listed, ", ")
+		if more > 0 {
+			names = fmt.Sprintf("%s and %d more", names, more)
+		}
+		return fmt.Sprintf("Artists playing %s, %s.", names, dates)
+	}
+
+	for listed := len(venues); listed > 0; listed-- {
+		description := describe(venues[:listed], len(venues)-listed)
+		if len(description) <= maximumDescriptionLength {
+			return description
+		}
+	}
+
+	return fmt.Sprintf("Artists playing %d venues, %s.", len(venues), dates)
+}
+
+func GetPlannedTrackIds(tracks []PlannedTrack) (ids []spotify.ID) {
+	for _, track := range tracks {
+		ids = append(ids, track.Id)
+	}
+	return
+}
+
+func NewPlan() *Plan {
+	return &Plan{
+		Created:   time.Now(),
+		Playlists: make([]*PlaylistPlan, 0),
+	}
+}
+
+// NewPlaylistPlan compares the tracks a playlist should have with the ones it
+// has. Tracks are added in the order given and removed in playlist order so
+// the plan is the same from one run to the next. With an order the whole
+// playlist is rearranged after that. Without a retention policy every track
+// that isn't in tracksAfter is removed.
+func NewPlaylistPlan(spotifyClient MusicService, user string, title string, playlist *spotify.SimplePlaylist, tracksAfter []PlannedTrack, order string, retention *RetentionPolicy) (*PlaylistPlan, error) {
+	pp := &PlaylistPlan{
+		Title:  title,
+		User:   user,
+		Add:    make([]PlannedTrack, 0),
+		Remove: make([]PlannedTrack, 0),
+	}
+
+	tracksBefore := []spotify.PlaylistTrack{}
+	if playlist != nil {
+		pp.PlaylistId = playlist.ID
+
+		var err error
+		tracksBefore, err = GetPlaylistTracks(spotifyClient, playlist.ID)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to get tracks for '%s': %v", title, err)
+		}
+	}
+
+	if retention != nil {
+		tracksAfter = retention.Apply(pp.PlaylistId, tracksBefore, tracksAfter)
+		pp.Retained = true
+	}
+
+	// Playlists list tracks as they were added, which may be the original of
+	// a relinked track, so either one counts as already being there.
+	before := NewTracksSetFromPlaylist(tracksBefore)
+	adding := NewEmptyTracksSet()
+	for _, track := range tracksAfter {
+		if before.Contains(track.Id) || (track.LinkedFrom != "" && before.Contains(track.LinkedFrom)) {
+			pp.Keep = append(pp.Keep, track)
+			continue
+		}
+		if !adding.Contains(track.Id) {
+			pp.Add = append(pp.Add, track)
+			adding.Add(track.Id)
+		}
+	}
+
+	after := NewTracksSet(GetPlannedTrackIds(tracksAfter))
+	for _, track := range tracksAfter {
+		if track.LinkedFrom != "" {
+			after.Add(track.LinkedFrom)
+		}
+	}
+	for _, track := range tracksBefore {
+		if !after.Contains(track.Track.ID) {
+			pp.Remove = append(pp.Remove, NewPlannedTrack(track.Track, ""))
+		}
+	}
+
+	if order != "" {
+		ordered, err := OrderTracks(tracksAfter, order)
+		if err != nil {
+			return nil, err
+		}
+
+		placed := NewEmptyTracksSet()
+		for _, track := range ordered {
+			id := track.Id
+			if !before.Contains(id) && track.LinkedFrom != "" && before.Contains(track.LinkedFrom) {
+				id = track.LinkedFrom
+			}
+			if !placed.Contains(id) {
+				pp.Order = append(pp.Order, id)
+				placed.Add(id)
+			}
+		}
+	}
+
+	return pp, nil
+}
+
+func (pp *PlaylistPlan) Print(w io.Writer) {
+	status := ""
+	if pp.PlaylistId == "" {
+		status = ", new playlist"
+	}
+	if len(pp.Order) > 0 {
+		status += ", reordered"
+	}
+	fmt.Fprintf(w, "%s (%d to add, %d to remove%s)\n", pp.Title, len(pp.Add), len(pp.Remove), status)
+
+	for _, track := range pp.Remove {
+		fmt.Fprintf(w, "  - %s - %s\n", track.Artist, track.Title)
+	}
+	for _, track := range pp.Add {
+		fmt.Fprintf(w, "  + %s - %s", track.Artist, track.Title)
+		if track.Event != "" {
+			fmt.Fprintf(w, "    [%s]", track.Event)
+		}
+		fmt.Fprintf(w, "\n")
+	}
+}
+
+// Apply makes the changes and records them in the ledger, which may be nil.
+func (pp *PlaylistPlan) Apply(spotifyClient MusicService, ledger *Ledger) error {
+	if pp.PlaylistId == "" {
+		log.Printf("Creating %v", pp.Title)
+
+		public := pp.Public == nil || *pp.Public
+		created, err := spotifyClient.CreatePlaylistForUser(pp.User, pp.Title, pp.Description, public)
+		if err != nil {
+			return fmt.Errorf("Unable to create playlist: %v", err)
+		}
+
+		pp.PlaylistId = created.ID
+	} else {
+		if pp.Description != "" {
+			if err := spotifyClient.ChangePlaylistDescription(pp.PlaylistId, pp.Description); err != nil {
+				return fmt.Errorf("Unable to change description: %v", err)
+			}
+		}
+		if pp.Public != nil {
+			if err := spotifyClient.ChangePlaylistAccess(pp.PlaylistId, *pp.Public); err != nil {
+				return fmt.Errorf("Unable to change access: %v", err)
+			}
+		}
+	}
+
+	log.Printf("Removing %d tracks from '%s'", len(pp.Remove), pp.Title)
+	err := RemoveTracksFromPlaylist(spotifyClient, pp.PlaylistId, GetPlannedTrackIds(pp.Remove))
+	if err != nil {
+		return err
+	}
+
+	ledger.Removed(pp.PlaylistId, pp.Remove)
+
+	log.Printf("Adding %d tracks to '%s'", len(pp.Add), pp.Title)
+	err = AddTracksToPlaylist(spotifyClient, pp.PlaylistId, GetPlannedTrackIds(pp.Add))
+	if err != nil {
+		return err
+	}
+
+	if pp.Retained {
+		now := time.Now()
+		ledger.Added(pp.PlaylistId, pp.Keep, now)
+		ledger.Added(pp.PlaylistId, pp.Add, now)
+	} else {
+		ledger.Forget(pp.PlaylistId)
+	}
+
+	if pp.Archive != nil {
+		pp.Archive.PlaylistId = pp.PlaylistId
+		ledger.Archived(*pp.Archive)
+	}
+
+	if len(pp.Order) == 0 {
+		return nil
+	}
+
+	log.Printf("Reordering '%s'", pp.Title)
+	return ReorderPlaylistTracks(spotifyClient, pp.PlaylistId, pp.Order)
+}
+
+func (p *Plan) Add(pp *PlaylistPlan) {
+	p.Playlists = append(p.Playlists, pp)
+}
+
+func (p *Plan) Print(w io.Writer) {
+	for _, pp := range p.Playlists {
+		pp.Print(w)
+		fmt.Fprintf(w, "\n")
+	}
+}
+
+func (p *Plan) Apply(spotifyClient MusicService, ledger *Ledger) error {
+	for _, pp := range p.Playlists {
+		if err := pp.Apply(spotifyClient, ledger); err != nil {
+			return fmt.Errorf("Unable to update '%s': %v", pp.Title, err)
+		}
+	}
+	return nil
+}
+
+func (p *Plan) Write(fileName string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(fileName, data, 0644)
+}
+
+func LoadPlan(fileName string) (*Plan, error) {
+	file, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{}
+	if err := json.Unmarshal(file, plan); err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %v", fileName, err)
+	}
+
+	return plan, nil
+}
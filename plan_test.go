@@ -0,0 +1,116 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zmb3/spotify"
+)
+
+func TestApplyWrittenPlan(t *testing.T) {
+	fake := newTestCatalog()
+	old, _ := fake.FindTrack("old")
+	z2, _ := fake.FindTrack("z2")
+	newTestPlaylist(fake, "jlewalle", "weekly", "new haven weekly", *old, *z2)
+
+	playlist, err := GetPlaylistByTitle(fake, "jlewalle", "new haven weekly")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracksAfter := make([]PlannedTrack, 0)
+	for _, id := range []spotify.ID{"z1", "z2", "r1"} {
+		track, _ := fake.FindTrack(id)
+		tracksAfter = append(tracksAfter, NewPlannedTrack(*track, "Zombii"))
+	}
+
+	plan := NewPlan()
+	pp, err := NewPlaylistPlan(fake, "jlewalle", "new haven weekly", playlist, tracksAfter, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pp.Description = "Artists playing 1 venues."
+	plan.Add(pp)
+
+	created, err := NewPlaylistPlan(fake, "jlewalle", "los angeles weekly", nil, tracksAfter[:1], "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan.Add(created)
+
+	fileName := filepath.Join(t.TempDir(), "plan.json")
+	if err := plan.Write(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadPlan(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Playlists) != 2 || !reflect.DeepEqual(loaded.Playlists[0].Add, pp.Add) || !reflect.DeepEqual(loaded.Playlists[0].Remove, pp.Remove) {
+		t.Fatalf("Expected the plan to load as it was written, got %+v", loaded.Playlists)
+	}
+
+	if err := loaded.Apply(fake, NewLedger()); err != nil {
+		t.Fatal(err)
+	}
+
+	pl, _ := fake.FindPlaylist("weekly")
+	if actual, expected := getTestPlaylistTrackIds(pl), []spotify.ID{"z2", "z1", "r1"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+	if pl.Description != pp.Description {
+		t.Errorf("Expected the description '%s', got '%s'", pp.Description, pl.Description)
+	}
+
+	createdPlaylist, err := GetPlaylistByTitle(fake, "jlewalle", "los angeles weekly")
+	if err != nil || createdPlaylist == nil {
+		t.Fatalf("Expected the missing playlist to be created: %v", err)
+	}
+	pl, _ = fake.FindPlaylist(createdPlaylist.ID)
+	if actual, expected := getTestPlaylistTrackIds(pl), []spotify.ID{"z1"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
+
+func TestApplyStalePlan(t *testing.T) {
+	fake := newTestCatalog()
+	z1, _ := fake.FindTrack("z1")
+
+	plan := NewPlan()
+	plan.Add(&PlaylistPlan{
+		Title:      "new haven weekly",
+		User:       "jlewalle",
+		PlaylistId: "deleted",
+		Add:        []PlannedTrack{NewPlannedTrack(*z1, "Zombii")},
+		Remove:     make([]PlannedTrack, 0),
+	})
+
+	fileName := filepath.Join(t.TempDir(), "plan.json")
+	if err := plan.Write(fileName); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadPlan(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = loaded.Apply(fake, NewLedger())
+	if err == nil || !strings.Contains(err.Error(), "Unable to update 'new haven weekly'") {
+		t.Errorf("Expected the stale plan to fail, got %v", err)
+	}
+	if len(fake.Playlists) != 0 {
+		t.Errorf("Expected no playlists to be created, got %d", len(fake.Playlists))
+	}
+}
+
+func TestLoadPlanErrors(t *testing.T) {
+	if _, err := LoadPlan(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("Expected a missing plan to fail")
+	}
+	if _, err := LoadPlan("testdata/events.json"); err == nil || !strings.Contains(err.Error(), "Unable to parse") {
+		t.Errorf("Expected a file that isn't a plan to fail, got %v", err)
+	}
+}
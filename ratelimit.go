@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxAttempts   = 5
+	defaultRequestBudget = 0
+	defaultBaseDelay     = 500 * time.Millisecond
+	defaultMaxDelay      = 30 * time.Second
+	defaultMaxRetryAfter = 2 * time.Minute
+)
+
+// RateLimitedTransport retries throttled (429) and, for requests that can be
+// repeated, temporarily unavailable responses. Retry-After is honored when present and applies to every request
+// going through the transport, otherwise the delay backs off exponentially
+// with jitter. Budget caps the number of requests made in total.
+type RateLimitedTransport struct {
+	Base          http.RoundTripper
+	MaxAttempts   int
+	Budget        int
+	BaseDelay     time.Duration
+	MaxDelay      time.Duration
+	MaxRetryAfter time.Duration
+	Sleep         func(time.Duration)
+	mutex         sync.Mutex
+	requests      int
+	pausedUntil   time.Time
+}
+
+func NewRateLimitedTransport(base http.RoundTripper) *RateLimitedTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &RateLimitedTransport{
+		Base:          base,
+		MaxAttempts:   defaultMaxAttempts,
+		Budget:        defaultRequestBudget,
+		BaseDelay:     defaultBaseDelay,
+		MaxDelay:      defaultMaxDelay,
+		MaxRetryAfter: defaultMaxRetryAfter,
+		Sleep:         time.Sleep,
+	}
+}
+
+func (t *RateLimitedTransport) Requests() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.requests
+}
+
+func (t *RateLimitedTransport) reserve() error {
+	t.mutex.Lock()
+	if t.Budget > 0 && t.requests >= t.Budget {
+		t.mutex.Unlock()
+		return fmt.Errorf("Request budget of %d exhausted", t.Budget)
+	}
+	t.requests++
+	wait := time.Until(t.pausedUntil)
+	t.mutex.Unlock()
+
+	if wait > 0 {
+		t.Sleep(wait)
+	}
+
+	return nil
+}
+
+func (t *RateLimitedTransport) pause(delay time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	until := time.Now().Add(delay)
+	if until.After(t.pausedUntil) {
+		t.pausedUntil = until
+	}
+}
+
+func (t *RateLimitedTransport) Backoff(attempt int) time.Duration {
+	delay := t.BaseDelay << uint(attempt-1)
+	if delay <= 0 || delay > t.MaxDelay {
+		delay = t.MaxDelay
+	}
+	half := int64(delay / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+func ParseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at), true
+	}
+	return 0, false
+}
+
+// IsRetryableStatus retries throttled requests whatever their method, they
+// weren't carried out. A 502/503/504 may come after the change was made, so
+// only requests that can safely be made twice are retried, a POST adding
+// tracks would add them again and a PUT reordering them would move a
+// different range.
+func IsRetryableStatus(method string, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		switch method {
+		case "", http.MethodGet, http.MethodHead, http.MethodDelete, http.MethodOptions:
+			return true
+		}
+	}
+	return false
+}
+
+func (t *RateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		if err := t.reserve(); err != nil {
+			return nil, err
+		}
+
+		attemptReq := req
+		if attempt > 1 && req.Body != nil {
+			if req.GetBody == nil {
+				return nil, fmt.Errorf("Unable to retry %s %s, body can't be replayed", req.Method, req.URL)
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+
+		resp, err := t.Base.RoundTrip(attemptReq)
+		if err != nil || !IsRetryableStatus(req.Method, resp.StatusCode) || attempt >= t.MaxAttempts {
+			return resp, err
+		}
+
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		default:
+		}
+
+		// Retry-After pauses every request, the next reserve waits it out.
+		if delay, ok := ParseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			if delay > t.MaxRetryAfter {
+				return nil, fmt.Errorf("%s asked to retry after %v", resp.Status, delay)
+			}
+			log.Printf("Throttled (%s), retrying after %v (%d/%d)...", resp.Status, delay, attempt, t.MaxAttempts)
+			t.pause(delay)
+		} else {
+			delay := t.Backoff(attempt)
+			log.Printf("Throttled (%s), retrying in %v (%d/%d)...", resp.Status, delay, attempt, t.MaxAttempts)
+			t.Sleep(delay)
+		}
+	}
+}
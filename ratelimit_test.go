@@ -0,0 +1,153 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimitedTransport(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		statuses    []int
+		retryAfter  string
+		maxAttempts int
+		budget      int
+		requests    int
+		status      int
+		err         string
+		minSlept    time.Duration
+		maxSlept    time.Duration
+	}{
+		{
+			name:       "waits for Retry-After",
+			method:     http.MethodGet,
+			statuses:   []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter: "2",
+			requests:   2,
+			status:     http.StatusOK,
+			minSlept:   1900 * time.Millisecond,
+			maxSlept:   2 * time.Second,
+		},
+		{
+			name:     "backs off without Retry-After",
+			method:   http.MethodGet,
+			statuses: []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			requests: 3,
+			status:   http.StatusOK,
+			minSlept: (defaultBaseDelay + 2*defaultBaseDelay) / 2,
+			maxSlept: defaultBaseDelay + 2*defaultBaseDelay,
+		},
+		{
+			name:        "gives up after the last attempt",
+			method:      http.MethodGet,
+			statuses:    []int{http.StatusTooManyRequests},
+			retryAfter:  "1",
+			maxAttempts: 3,
+			requests:    3,
+			status:      http.StatusTooManyRequests,
+			minSlept:    1800 * time.Millisecond,
+			maxSlept:    2 * time.Second,
+		},
+		{
+			name:       "retries throttled posts",
+			method:     http.MethodPost,
+			statuses:   []int{http.StatusTooManyRequests, http.StatusCreated},
+			retryAfter: "1",
+			requests:   2,
+			status:     http.StatusCreated,
+			minSlept:   900 * time.Millisecond,
+			maxSlept:   time.Second,
+		},
+		{
+			name:     "doesn't retry posts that may have happened",
+			method:   http.MethodPost,
+			statuses: []int{http.StatusBadGateway, http.StatusCreated},
+			requests: 1,
+			status:   http.StatusBadGateway,
+		},
+		{
+			name:     "doesn't retry reorders that may have happened",
+			method:   http.MethodPut,
+			statuses: []int{http.StatusServiceUnavailable, http.StatusOK},
+			requests: 1,
+			status:   http.StatusServiceUnavailable,
+		},
+		{
+			name:       "stops at the budget",
+			method:     http.MethodGet,
+			statuses:   []int{http.StatusTooManyRequests},
+			retryAfter: "1",
+			budget:     2,
+			requests:   2,
+			err:        "budget of 2 exhausted",
+			minSlept:   900 * time.Millisecond,
+			maxSlept:   time.Second,
+		},
+		{
+			name:       "won't wait too long",
+			method:     http.MethodGet,
+			statuses:   []int{http.StatusTooManyRequests},
+			retryAfter: "3600",
+			requests:   1,
+			err:        "retry after 1h0m0s",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mutex sync.Mutex
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mutex.Lock()
+				status := test.statuses[min(requests, len(test.statuses)-1)]
+				requests++
+				mutex.Unlock()
+
+				if status == http.StatusTooManyRequests && test.retryAfter != "" {
+					w.Header().Set("Retry-After", test.retryAfter)
+				}
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			slept := time.Duration(0)
+			transport := NewRateLimitedTransport(http.DefaultTransport)
+			transport.Sleep = func(d time.Duration) { slept += d }
+			transport.Budget = test.budget
+			if test.maxAttempts > 0 {
+				transport.MaxAttempts = test.maxAttempts
+			}
+
+			req, err := http.NewRequest(test.method, server.URL, strings.NewReader("{}"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := transport.RoundTrip(req)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("Expected an error with '%s', got %v", test.err, err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else {
+				resp.Body.Close()
+				if resp.StatusCode != test.status {
+					t.Errorf("Expected %d, got %d", test.status, resp.StatusCode)
+				}
+			}
+
+			if requests != test.requests || transport.Requests() != test.requests {
+				t.Errorf("Expected %d requests, server got %d and transport made %d", test.requests, requests, transport.Requests())
+			}
+			if slept < test.minSlept || slept > test.maxSlept {
+				t.Errorf("Expected to sleep %v-%v, slept %v", test.minSlept, test.maxSlept, slept)
+			}
+		})
+	}
+}
@@ -6,10 +6,50 @@ import (
 	"log"
 )
 
+type Venue struct {
+	Source string
+	Id     string
+	Name   string
+	Url    string
+	Path   string
+}
+
 type Region struct {
-	Id       string
-	Region   string
-	VenueIds []string
+	Id            string
+	Region        string
+	VenueIds      []string
+	Venues        []Venue
+	Window        *WindowOptions
+	Genres        []string
+	MinPopularity int
+	MaxPopularity int
+	Tracks        *TrackOptions
+	Market        string
+	Order         string
+	Retention     *RetentionOptions
+	Archive       *ArchiveOptions
+	Public        *bool
+}
+
+const defaultMarket = "US"
+
+// GetMarket is the country code searches, top tracks and playability are
+// checked against.
+func (r *Region) GetMarket() string {
+	if r == nil || r.Market == "" {
+		return defaultMarket
+	}
+	return r.Market
+}
+
+func (r *Region) GetVenues() (venues []Venue) {
+	for _, id := range r.VenueIds {
+		venues = append(venues, Venue{Source: FacebookSource, Id: id})
+	}
+
+	venues = append(venues, r.Venues...)
+
+	return
 }
 
 func LoadRegions(fileName string) (regions []Region) {
@@ -17,7 +57,7 @@ func LoadRegions(fileName string) (regions []Region) {
 
 	file, e := ioutil.ReadFile(fileName)
 	if e != nil {
-		log.Fatal("File error: %v\n", e)
+		log.Fatalf("File error: %v\n", e)
 	}
 
 	json.Unmarshal(file, &regions)
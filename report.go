@@ -2,50 +2,210 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"html/template"
 	"log"
-	"net/smtp"
-	"strconv"
-)
+	"strings"
+	textTemplate "text/template"
+	"time"
 
-type EmailUser struct {
-	Username    string
-	Password    string
-	EmailServer string
-	Port        int
-}
+	"github.com/zmb3/spotify"
+)
 
 type SmtpTemplateData struct {
 	From    string
 	To      string
 	Subject string
-	Body    string
+	Report  *RunReport
 }
 
-func SendEmail(info string) {
-	var err error
+// RunReport is what happened to every region in a run, for the email. Errors
+// are failures that aren't any one region's, like the mbe playlist's.
+type RunReport struct {
+	Started time.Time
+	DryRun  bool
+	Regions []*RegionReport
+	Errors  []string
+}
 
-	data := &SmtpTemplateData{
-		"jcl.automated@gmail.com",
-		"jlewalle@gmail.com",
-		"weekly-playlist: Report",
-		info,
+// RegionReport's Venues hold every event with the guesses made for it, the
+// artists found and the tracks chosen. Added and Removed are the changes to
+// the region's playlist and Errors are the venues and searches that failed
+// and the changes Spotify refused.
+type RegionReport struct {
+	Region      string
+	Playlist    string
+	PlaylistId  spotify.ID
+	Window      Window
+	Venues      []*Resolution
+	Added       []PlannedTrack
+	Removed     []PlannedTrack
+	NearMisses  []NearMiss
+	Ambiguities []Ambiguity
+	Unplayable  []PlannedTrack
+	Errors      []string
+	Elapsed     time.Duration
+}
+
+type ReportCounts struct {
+	Venues     int
+	Events     int
+	Unresolved int
+	Artists    int
+	Tracks     int
+	Added      int
+	Removed    int
+}
+
+func NewRunReport(started time.Time, dryRun bool) *RunReport {
+	return &RunReport{
+		Started: started,
+		DryRun:  dryRun,
+		Regions: make([]*RegionReport, 0),
+	}
+}
+
+func (r *RunReport) Add(rr *RegionReport) {
+	r.Regions = append(r.Regions, rr)
+}
+
+func (r *RunReport) AddError(err string) {
+	r.Errors = append(r.Errors, err)
+}
+
+func (r *RunReport) Counts() (counts ReportCounts) {
+	for _, rr := range r.Regions {
+		counts.Add(rr.Counts())
+	}
+	return
+}
+
+func (c *ReportCounts) Add(other ReportCounts) {
+	c.Venues += other.Venues
+	c.Events += other.Events
+	c.Unresolved += other.Unresolved
+	c.Artists += other.Artists
+	c.Tracks += other.Tracks
+	c.Added += other.Added
+	c.Removed += other.Removed
+}
+
+func (rr *RegionReport) Counts() (counts ReportCounts) {
+	counts.Venues = len(rr.Venues)
+	for _, res := range rr.Venues {
+		for _, resolved := range res.Events {
+			counts.Events++
+			counts.Artists += len(resolved.Artists)
+			counts.Tracks += len(resolved.Tracks)
+			if len(resolved.Tracks) == 0 {
+				counts.Unresolved++
+			}
+		}
+	}
+	counts.Added = len(rr.Added)
+	counts.Removed = len(rr.Removed)
+	return
+}
+
+// Failed is when no tracks were found for the region or something in it
+// failed.
+func (rr *RegionReport) Failed() bool {
+	return rr.Counts().Tracks == 0 || len(rr.Errors) > 0
+}
+
+func (r *RunReport) Failed() bool {
+	for _, rr := range r.Regions {
+		if rr.Failed() {
+			return true
+		}
+	}
+	return len(r.Errors) > 0
+}
+
+// HasErrors is when something failed, unlike Failed a region that just found
+// no tracks doesn't count.
+func (r *RunReport) HasErrors() bool {
+	for _, rr := range r.Regions {
+		if len(rr.Errors) > 0 {
+			return true
+		}
+	}
+	return len(r.Errors) > 0
+}
+
+// Unresolved are the events that didn't end up with any tracks.
+func (rr *RegionReport) Unresolved() (unresolved []*ResolvedEvent) {
+	for _, res := range rr.Venues {
+		for _, resolved := range res.Events {
+			if len(resolved.Tracks) == 0 {
+				unresolved = append(unresolved, resolved)
+			}
+		}
 	}
+	return
+}
 
-	body, err := ParseTemplate("report.html", data)
+// SendEmail sends the whole report to the config's To and each region's
+// part of it to that region's recipients.
+func SendEmail(email EmailConfig, report *RunReport) {
+	recipients := len(email.To)
+	for _, rr := range report.Regions {
+		recipients += len(email.Regions[rr.Region])
+	}
+	if recipients == 0 {
+		log.Printf("WARNING: No one to email the report to, set Email.To in the config or WEEKLY_EMAIL_TO")
+		return
+	}
 
-	mime := "MIME-version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";\n\n"
-	subject := "Subject: " + data.Subject + "\n"
-	msg := []byte(subject + mime + "\n" + body)
+	if len(email.To) > 0 {
+		if err := SendReport(email, email.To, report); err != nil {
+			log.Printf("ERROR: Unable to send email: %v", err)
+		}
+	}
 
-	emailUser := &EmailUser{smtpUsername, smtpPassword, "smtp.gmail.com", 587}
-	auth := smtp.PlainAuth("", emailUser.Username, emailUser.Password, emailUser.EmailServer)
-	server := emailUser.EmailServer + ":" + strconv.Itoa(emailUser.Port)
+	for _, rr := range report.Regions {
+		recipients := email.Regions[rr.Region]
+		if len(recipients) == 0 {
+			continue
+		}
 
-	err = smtp.SendMail(server, auth, emailUser.Username, []string{"jlewalle@gmail.com"}, msg)
+		regionReport := NewRunReport(report.Started, report.DryRun)
+		regionReport.Add(rr)
+		if err := SendReport(email, recipients, regionReport); err != nil {
+			log.Printf("ERROR: Unable to send %s email: %v", rr.Region, err)
+		}
+	}
+}
+
+func SendReport(email EmailConfig, to []string, report *RunReport) error {
+	subject := fmt.Sprintf("weekly-playlist: Report for %s", report.Started.Format("Jan 2"))
+	if len(report.Regions) == 1 {
+		subject = fmt.Sprintf("weekly-playlist: %s report for %s", report.Regions[0].Region, report.Started.Format("Jan 2"))
+	}
+
+	data := &SmtpTemplateData{
+		email.GetFrom(),
+		strings.Join(to, ", "),
+		subject,
+		report,
+	}
+
+	html, err := ParseTemplate("report.html", data)
 	if err != nil {
-		log.Print("ERROR: Unable to send email", err)
+		return fmt.Errorf("Unable to render report: %v", err)
 	}
+
+	text, err := ParseTextTemplate("report.txt", data)
+	if err != nil {
+		return fmt.Errorf("Unable to render report: %v", err)
+	}
+
+	msg, err := NewAlternativeMessage(data.From, to, data.Subject, text, html)
+	if err != nil {
+		return fmt.Errorf("Unable to build email: %v", err)
+	}
+
+	return email.Send(to, msg)
 }
 
 func ParseTemplate(templateFileName string, data interface{}) (body string, err error) {
@@ -60,3 +220,16 @@ func ParseTemplate(templateFileName string, data interface{}) (body string, err
 	body = buf.String()
 	return
 }
+
+func ParseTextTemplate(templateFileName string, data interface{}) (body string, err error) {
+	t, err := textTemplate.ParseFiles(templateFileName)
+	if err != nil {
+		return
+	}
+	buf := new(bytes.Buffer)
+	if err = t.Execute(buf, data); err != nil {
+		return
+	}
+	body = buf.String()
+	return
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zmb3/spotify"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden reports in testdata")
+
+func newTestRunReport(t *testing.T) *RunReport {
+	fake := newTestCatalog()
+	old, _ := fake.FindTrack("old")
+	z2, _ := fake.FindTrack("z2")
+	newTestPlaylist(fake, "jlewalle", "weekly", "new haven weekly", *old, *z2)
+
+	run := &RegionRun{
+		Client:   fake,
+		Resolver: NewArtistResolver(nil, nil, nil),
+		Sources:  NewEventSources(),
+		Config:   NewConfig(),
+		Owner:    "jlewalle",
+		Ledger:   NewLedger(),
+		Plan:     NewPlan(),
+		Options:  Options{DryRun: true},
+		Now:      time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	rr, err := run.UpdateRegion(Region{
+		Region: "new haven",
+		Venues: []Venue{
+			{Source: JsonSource, Name: "Cafe Nine", Path: "testdata/events.json"},
+			{Source: "myspace", Name: "Nowhere"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr.NearMisses = append(rr.NearMisses, NearMiss{Guess: "Zombie", Venue: "Cafe Nine", Candidate: "Zombii", ArtistId: "zombii", Score: 0.83})
+
+	report := NewRunReport(run.Now, true)
+	report.Add(rr)
+	report.AddError("Unable to update 'mbe 17/05/28': <b>rate limited</b>")
+	return report
+}
+
+// The reports are compared with testdata/report.*.golden, after changing the
+// templates run the tests with -update and check the difference.
+func TestRenderReport(t *testing.T) {
+	data := &SmtpTemplateData{
+		From:    "weekly@example.com",
+		To:      "jlewalle@example.com",
+		Subject: "weekly-playlist: new haven report for Jun 1",
+		Report:  newTestRunReport(t),
+	}
+
+	tests := []struct {
+		name   string
+		render func(string, interface{}) (string, error)
+		golden string
+	}{
+		{"report.txt", ParseTextTemplate, "testdata/report.txt.golden"},
+		{"report.html", ParseTemplate, "testdata/report.html.golden"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := test.render(test.name, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if *updateGolden {
+				if err := ioutil.WriteFile(test.golden, []byte(actual), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			expected, err := ioutil.ReadFile(test.golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != string(expected) {
+				t.Errorf("Expected %s to match %s, got:\n%s", test.name, test.golden, actual)
+			}
+		})
+	}
+}
+
+func TestReportShowsChosenTracks(t *testing.T) {
+	report := newTestRunReport(t)
+
+	tracks := make([]spotify.ID, 0)
+	for _, res := range report.Regions[0].Venues {
+		for _, resolved := range res.Events {
+			tracks = append(tracks, GetPlannedTrackIds(resolved.Tracks)...)
+		}
+	}
+	if len(tracks) == 0 {
+		t.Fatalf("Expected the report to have chosen tracks")
+	}
+
+	data := &SmtpTemplateData{Report: report}
+	text, err := ParseTextTemplate("report.txt", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html, err := ParseTemplate("report.html", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range tracks {
+		link := "https://open.spotify.com/track/" + string(id)
+		if !strings.Contains(text, link) || !strings.Contains(html, link) {
+			t.Errorf("Expected the chosen track %s in both reports", id)
+		}
+	}
+}
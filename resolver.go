@@ -0,0 +1,444 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zmb3/spotify"
+)
+
+const defaultWorkers = 4
+
+type ArtistResolver struct {
+	artistCache *ArtistCache
+	overrides   *ArtistOverrides
+	matcher     *ArtistMatcher
+	mutex       sync.Mutex
+	venueGenres map[string]map[string]int
+}
+
+// ResolvedArtist is an artist found for an event. Position is the order they
+// appear in the event's title, Role comes from the cues around them and Path
+// is the steps of the guesses that led to them.
+type ResolvedArtist struct {
+	Guess    string
+	Path     []string
+	Artist   *spotify.FullArtist
+	Position int
+	Role     string
+}
+
+type ResolvedEvent struct {
+	Event   Event
+	Artists []*ResolvedArtist
+	Tracks  []PlannedTrack
+}
+
+// Resolution holds everything found for one venue. Venues are resolved in
+// parallel, so log lines are buffered and written out in venue order
+// afterwards to keep the output the same from one run to the next. VenueKey
+// is the venue's Id in the regions file, Venue the name on the event being
+// resolved.
+type Resolution struct {
+	Region      *Region
+	Selection   *TrackSelection
+	VenueName   string
+	VenueKey    string
+	Venue       string
+	Log         *log.Logger
+	Buffer      *bytes.Buffer
+	Events      []*ResolvedEvent
+	NearMisses  []NearMiss
+	Ambiguities []Ambiguity
+	Unplayable  []PlannedTrack
+	Errors      []string
+	Elapsed     time.Duration
+	Trace       map[*ArtistGuess]*GuessTrace
+	artists     []*ResolvedArtist
+	guesses     *ArtistGuess
+	cached      bool
+}
+
+func NewResolution(region *Region, selection *TrackSelection) *Resolution {
+	buffer := new(bytes.Buffer)
+	return &Resolution{
+		Region:    region,
+		Selection: selection,
+		Log:       log.New(buffer, "", log.LstdFlags),
+		Buffer:    buffer,
+		Events:    make([]*ResolvedEvent, 0),
+	}
+}
+
+// AddArtist keeps artists in the order they're found, which follows the order
+// of the event's title. An artist found twice keeps their first position.
+func (res *Resolution) AddArtist(guess *ArtistGuess, artist *spotify.FullArtist) bool {
+	for _, resolved := range res.artists {
+		if resolved.Artist.ID == artist.ID {
+			return false
+		}
+	}
+	res.artists = append(res.artists, &ResolvedArtist{
+		Guess:    guess.Name,
+		Path:     GuessPath(res.guesses, guess),
+		Artist:   artist,
+		Position: len(res.artists),
+		Role:     guess.Role,
+	})
+	return true
+}
+
+// GuessTrace is what became of a guess that was searched for, recorded when
+// the Resolution has a Trace. Cached is when the outcome came from the artist
+// cache rather than Spotify and Errors are the lookups that failed.
+type GuessTrace struct {
+	Artist    *spotify.FullArtist
+	Skipped   bool
+	Cached    bool
+	NearMiss  *NearMiss
+	Ambiguity *Ambiguity
+	Errors    []string
+}
+
+// TraceGuess records guess's outcome, the near misses, ambiguities and errors
+// added since there were nearMisses, ambiguities and errors of them came from
+// guess.
+func (res *Resolution) TraceGuess(guess *ArtistGuess, found *spotify.FullArtist, skip bool, nearMisses int, ambiguities int, errors int) {
+	if res.Trace == nil {
+		return
+	}
+
+	trace := &GuessTrace{Artist: found, Skipped: skip, Cached: res.cached}
+	if len(res.NearMisses) > nearMisses {
+		trace.NearMiss = &res.NearMisses[len(res.NearMisses)-1]
+	}
+	if len(res.Ambiguities) > ambiguities {
+		trace.Ambiguity = &res.Ambiguities[len(res.Ambiguities)-1]
+	}
+	if len(res.Errors) > errors {
+		trace.Errors = res.Errors[errors:]
+	}
+	res.Trace[guess] = trace
+}
+
+func (res *Resolution) Tracks() (tracks []PlannedTrack) {
+	for _, resolved := range res.Events {
+		tracks = append(tracks, resolved.Tracks...)
+	}
+	return
+}
+
+// AddError logs an error that didn't stop the venue, say a search Spotify
+// refused, and keeps it for the report.
+func (res *Resolution) AddError(err error) {
+	res.Log.Printf("Error: %v", err)
+	if res.VenueKey != "" {
+		res.Errors = append(res.Errors, fmt.Sprintf("%s: %v", res.VenueKey, err))
+	} else {
+		res.Errors = append(res.Errors, err.Error())
+	}
+}
+
+func (res *Resolution) AddAmbiguity(ambiguity Ambiguity) {
+	ambiguity.Venue = res.Venue
+	res.Ambiguities = append(res.Ambiguities, ambiguity)
+}
+
+func (res *Resolution) AddNearMiss(nearMiss NearMiss) {
+	nearMiss.Venue = res.Venue
+	res.NearMisses = append(res.NearMisses, nearMiss)
+}
+
+func NewArtistResolver(artistCache *ArtistCache, overrides *ArtistOverrides, matcher *ArtistMatcher) (resolver *ArtistResolver) {
+	if artistCache == nil {
+		artistCache = NewArtistCache(defaultArtistCacheTTL, defaultArtistCacheNegativeTTL)
+	}
+	if matcher == nil {
+		matcher = NewArtistMatcher(defaultMatchThreshold)
+	}
+
+	resolver = new(ArtistResolver)
+	resolver.artistCache = artistCache
+	resolver.overrides = overrides
+	resolver.matcher = matcher
+	resolver.venueGenres = make(map[string]map[string]int)
+
+	return resolver
+}
+
+func (resolver *ArtistResolver) GetArtistContext(res *Resolution) *ArtistContext {
+	if res.Region == nil {
+		return nil
+	}
+
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+
+	history := make(map[string]int)
+	for genre, count := range resolver.venueGenres[res.VenueKey] {
+		history[genre] = count
+	}
+
+	return &ArtistContext{
+		Genres:        res.Region.Genres,
+		MinPopularity: res.Region.MinPopularity,
+		MaxPopularity: res.Region.MaxPopularity,
+		History:       history,
+	}
+}
+
+func (resolver *ArtistResolver) AddToVenueHistory(res *Resolution, artist *spotify.FullArtist) {
+	resolver.mutex.Lock()
+	defer resolver.mutex.Unlock()
+
+	history := resolver.venueGenres[res.VenueKey]
+	if history == nil {
+		history = make(map[string]int)
+		resolver.venueGenres[res.VenueKey] = history
+	}
+	for _, genre := range artist.Genres {
+		history[genre]++
+	}
+}
+
+// Search leaves retrying throttled requests to RateLimitedTransport.
+func (resolver *ArtistResolver) Search(spotifyClient MusicService, market string, st spotify.SearchType, term string) (sr *spotify.SearchResult, err error) {
+	return spotifyClient.SearchOpt(term, st, &spotify.Options{Country: &market})
+}
+
+func (resolver *ArtistResolver) SearchForArtist(spotifyClient MusicService, res *Resolution, depth int, name string) (*spotify.FullArtist, error) {
+	market := res.Region.GetMarket()
+	cached, ok := resolver.artistCache.Get(market, name)
+	res.cached = ok
+	if ok {
+		if cached.Found {
+			res.Log.Printf("      [%-4s]%s%s\n", "$$$$", strings.Repeat("  ", depth), cached.Artist.Name)
+		} else if cached.NearMiss != nil {
+			res.AddNearMiss(*cached.NearMiss)
+		}
+		return cached.Artist, nil
+	}
+
+	found, err := resolver.Search(spotifyClient, market, spotify.SearchTypeArtist, name)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to search for '%s': %v", name, err)
+	}
+
+	if found.Artists != nil {
+		choice := resolver.matcher.Choose(name, found.Artists.Artists, resolver.GetArtistContext(res))
+		if choice.Artist != nil {
+			res.Log.Printf("      [%-4s]%s%s (%.2f)\n", "****", strings.Repeat("  ", depth), choice.Artist.Name, choice.Score)
+			// Only unique names are cached, when several artists share a
+			// name another region or venue may well pick a different one.
+			if choice.Candidates == 1 && !choice.Contextual {
+				resolver.artistCache.Put(market, name, choice.Artist)
+			}
+			return choice.Artist, nil
+		}
+
+		if choice.Ambiguity != nil {
+			res.Log.Printf("      [%-4s]%s%s (%s)\n", "????", strings.Repeat("  ", depth), name, choice.Ambiguity.Reason)
+			res.AddAmbiguity(*choice.Ambiguity)
+			return nil, nil
+		}
+
+		if choice.NearMiss != nil {
+			res.Log.Printf("      [%-4s]%s%s (%.2f)\n", "????", strings.Repeat("  ", depth), choice.NearMiss.Candidate, choice.NearMiss.Score)
+			res.AddNearMiss(*choice.NearMiss)
+			resolver.artistCache.PutNearMiss(market, name, choice.NearMiss)
+			return nil, nil
+		}
+	}
+
+	resolver.artistCache.Put(market, name, nil)
+
+	return nil, nil
+}
+
+func (resolver *ArtistResolver) GetArtistById(spotifyClient MusicService, res *Resolution, depth int, id spotify.ID) (*spotify.FullArtist, error) {
+	cached, ok := resolver.artistCache.GetById(id)
+	res.cached = ok && cached.Found
+	if res.cached {
+		res.Log.Printf("      [%-4s]%s%s\n", "$$$$", strings.Repeat("  ", depth), cached.Artist.Name)
+		return cached.Artist, nil
+	}
+
+	artist, err := spotifyClient.GetArtist(id)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get artist %s: %v", id, err)
+	}
+
+	res.Log.Printf("      [%-4s]%s%s\n", "!!!!", strings.Repeat("  ", depth), artist.Name)
+	resolver.artistCache.PutById(id, artist)
+
+	return artist, nil
+}
+
+func (resolver *ArtistResolver) ResolveGuess(spotifyClient MusicService, res *Resolution, depth int, artist *ArtistGuess) (found *spotify.FullArtist, skip bool) {
+	override := resolver.overrides.Find(artist.Name, res.VenueKey, res.Venue)
+	if override != nil {
+		if override.Skip {
+			res.Log.Printf("      [%-4s]%s%s\n", "SKIP", strings.Repeat("  ", depth), artist.Name)
+			return nil, true
+		}
+
+		if override.ArtistId != "" {
+			found, err := resolver.GetArtistById(spotifyClient, res, depth, override.ArtistId)
+			if err != nil {
+				res.AddError(err)
+			}
+			return found, false
+		}
+
+		for _, alias := range override.Aliases {
+			found, err := resolver.SearchForArtist(spotifyClient, res, depth, alias)
+			if err != nil {
+				res.AddError(err)
+			} else if found != nil {
+				return found, false
+			}
+		}
+	}
+
+	found, err := resolver.SearchForArtist(spotifyClient, res, depth, artist.Name)
+	if err != nil {
+		res.AddError(err)
+	}
+
+	return found, false
+}
+
+func (resolver *ArtistResolver) GetSpotifyArtistsForGuess(spotifyClient MusicService, res *Resolution, depth int, artist *ArtistGuess) {
+	res.Log.Printf("      [%-4s]%s%s\n", artist.Step, strings.Repeat("  ", depth), artist.Name)
+
+	// An override that skips or pins the guess always applies, even to an
+	// event's title when its performers are listed.
+	override := resolver.overrides.Find(artist.Name, res.VenueKey, res.Venue)
+	pinned := override != nil && (override.Skip || override.ArtistId != "")
+
+	// Presenters are on the bill but it isn't their show, they aren't
+	// searched for and get no tracks.
+	if artist.Role == PresenterRole && !pinned {
+		return
+	}
+
+	anyFound := false
+
+	// Structured performers are only listed as children, there's no need to
+	// search for the event title.
+	if artist.Step != PerformersStep || pinned {
+		nearMisses, ambiguities, errors := len(res.NearMisses), len(res.Ambiguities), len(res.Errors)
+		res.cached = false
+		found, skip := resolver.ResolveGuess(spotifyClient, res, depth, artist)
+		res.TraceGuess(artist, found, skip, nearMisses, ambiguities, errors)
+		if skip {
+			return
+		}
+		if found != nil {
+			if res.AddArtist(artist, found) {
+				resolver.AddToVenueHistory(res, found)
+			}
+			anyFound = true
+		}
+	}
+
+	if !anyFound {
+		for _, child := range artist.Children {
+			resolver.GetSpotifyArtistsForGuess(spotifyClient, res, depth+1, child)
+		}
+	}
+}
+
+func (resolver *ArtistResolver) GetSpotifyArtists(spotifyClient MusicService, res *Resolution, event Event) (spotifyArtists []*ResolvedArtist) {
+	res.artists = make([]*ResolvedArtist, 0)
+	res.guesses = event.Artists
+	res.Venue = event.Venue
+
+	resolver.GetSpotifyArtistsForGuess(spotifyClient, res, 0, event.Artists)
+
+	// Headliners go first, otherwise keep the order of the bill.
+	spotifyArtists = res.artists
+	sort.SliceStable(spotifyArtists, func(i, j int) bool {
+		return RoleRank(spotifyArtists[i].Role) < RoleRank(spotifyArtists[j].Role)
+	})
+
+	return
+}
+
+// ResolveVenue resolves a venue's events one after another, later events
+// lean on the genres of the artists found earlier at the same venue.
+func (resolver *ArtistResolver) ResolveVenue(spotifyClient MusicService, res *Resolution, source EventSource, window Window) {
+	res.VenueKey = GetVenueKey(source.GetVenue())
+	venueName, events := ProcessVenue(source, window, res)
+	res.VenueName = venueName
+
+	for _, event := range events {
+		res.Log.Printf("   '%s'\n", event.Name)
+
+		resolved := &ResolvedEvent{Event: event}
+		resolved.Artists = resolver.GetSpotifyArtists(spotifyClient, res, event)
+		for _, artist := range resolved.Artists {
+			artistTracks, err := res.Selection.SelectTracks(spotifyClient, artist.Artist, artist.Role)
+			if err != nil {
+				res.AddError(fmt.Errorf("Unable to get tracks for '%s': %v", artist.Artist.Name, err))
+			}
+			for _, track := range artistTracks {
+				planned := NewPlannedTrackForEvent(track, event)
+				if !IsPlayableIn(track, res.Selection.Market) {
+					res.Unplayable = append(res.Unplayable, planned)
+					continue
+				}
+				resolved.Tracks = append(resolved.Tracks, planned)
+			}
+			res.Log.Printf("   %d tracks '%s' (%s)\n", len(artistTracks), artist.Artist.Name, artist.Role)
+		}
+		if len(resolved.Artists) == 0 {
+			res.Log.Printf("   NO TRACKS")
+		}
+
+		res.Log.Printf("")
+
+		res.Events = append(res.Events, resolved)
+	}
+}
+
+// ResolveVenues hands venues to a fixed number of workers that all share the
+// same client and so the same rate limiter. Resolutions are returned in the
+// order of the sources regardless of which finished first.
+func (resolver *ArtistResolver) ResolveVenues(spotifyClient MusicService, region *Region, selection *TrackSelection, sources []EventSource, window Window, workers int) []*Resolution {
+	if workers < 1 {
+		workers = 1
+	}
+
+	resolutions := make([]*Resolution, len(sources))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				started := time.Now()
+				res := NewResolution(region, selection)
+				resolver.ResolveVenue(spotifyClient, res, sources[i], window)
+				res.Elapsed = time.Since(started)
+				resolutions[i] = res
+			}
+		}()
+	}
+
+	for i := range sources {
+		jobs <- i
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	return resolutions
+}
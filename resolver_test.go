@@ -0,0 +1,215 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zmb3/spotify"
+)
+
+func TestSearchForArtistCachesUniqueNames(t *testing.T) {
+	fake := NewFakeMusicService("jlewalle")
+	fake.Artists = []spotify.FullArtist{
+		newTestArtist("rap", "Residents", 50, "rap"),
+		newTestArtist("punk", "Residents", 10, "punk"),
+		newTestArtist("zombii", "Zombii", 20, "punk"),
+	}
+
+	cache := NewArtistCache(defaultArtistCacheTTL, defaultArtistCacheNegativeTTL)
+	resolver := NewArtistResolver(cache, nil, nil)
+
+	tests := []struct {
+		name     string
+		region   *Region
+		guess    string
+		expected spotify.ID
+	}{
+		{"anywhere", &Region{Region: "anywhere"}, "Residents", "rap"},
+		{"punk region", &Region{Region: "punk", Genres: []string{"punk"}}, "Residents", "punk"},
+		{"unique name", &Region{Region: "anywhere"}, "Zombii", "zombii"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := NewResolution(test.region, nil)
+			found, err := resolver.SearchForArtist(fake, res, 0, test.guess)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found == nil || found.ID != test.expected {
+				t.Errorf("Expected '%s', got %v", test.expected, found)
+			}
+		})
+	}
+
+	if _, ok := cache.Get(defaultMarket, "Residents"); ok {
+		t.Errorf("Expected a name shared by several artists not to be cached")
+	}
+	if cached, ok := cache.Get(defaultMarket, "Zombii"); !ok || cached.ArtistId != "zombii" {
+		t.Errorf("Expected a unique name to be cached, got %v", cached)
+	}
+}
+
+func TestVenueHistoryIsKeyedById(t *testing.T) {
+	fake := newTestCatalog()
+	resolver := NewArtistResolver(nil, nil, nil)
+	region := &Region{Region: "anywhere"}
+
+	window := Window{
+		From: time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2017, 6, 8, 0, 0, 0, 0, time.UTC),
+	}
+
+	// Both venues have the same name, only the Ids tell them apart.
+	sources := []EventSource{
+		&JsonEventSource{venue: Venue{Source: JsonSource, Id: "cafenineNH", Name: "Cafe Nine", Path: "testdata/events.json"}},
+		&JsonEventSource{venue: Venue{Source: JsonSource, Name: "Cafe Nine", Path: "testdata/events.json"}},
+	}
+
+	selection, err := NewTrackSelection(nil, defaultMarket, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolutions := resolver.ResolveVenues(fake, region, selection, sources, window, 1)
+	if resolutions[0].VenueKey != "cafenineNH" || resolutions[1].VenueKey != "Cafe Nine" {
+		t.Errorf("Expected venues keyed by Id then name, got '%s' and '%s'", resolutions[0].VenueKey, resolutions[1].VenueKey)
+	}
+
+	for _, key := range []string{"cafenineNH", "Cafe Nine"} {
+		if history := resolver.venueGenres[key]; history["punk"] != 1 || history["noise"] != 1 {
+			t.Errorf("Expected '%s' to have its own history, got %v", key, history)
+		}
+	}
+}
+
+func TestPresentersArentPlayed(t *testing.T) {
+	fake := newTestCatalog()
+	fake.Artists = append(fake.Artists, newTestArtist("kcrw", "KCRW", 40, "radio"))
+	fake.TopTracks["kcrw"] = []spotify.FullTrack{newTestTrack("k1", "Station ID", fake.Artists[len(fake.Artists)-1])}
+
+	resolver := NewArtistResolver(nil, nil, nil)
+	selection, err := NewTrackSelection(nil, defaultMarket, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		title    string
+		expected []string
+	}{
+		{"KCRW presents Zombii", []string{"Zombii headliner"}},
+		{"KCRW presents Zombii with RYXNO", []string{"Zombii headliner", "RYXNO support"}},
+		{"Zombii with RYXNO", []string{"Zombii headliner", "RYXNO support"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.title, func(t *testing.T) {
+			res := NewResolution(&Region{Region: "anywhere"}, selection)
+			event := Event{Name: test.title, Venue: "Cafe Nine", Artists: GuessArtistsForEvent(test.title)}
+
+			actual := make([]string, 0)
+			for _, artist := range resolver.GetSpotifyArtists(fake, res, event) {
+				actual = append(actual, artist.Artist.Name+" "+artist.Role)
+			}
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestResolveVenueKeepsErrors(t *testing.T) {
+	fake := newTestCatalog()
+	fake.RateLimitEvery = 1
+	resolver := NewArtistResolver(nil, nil, nil)
+	region := &Region{Region: "anywhere"}
+
+	window := Window{
+		From: time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2017, 6, 8, 0, 0, 0, 0, time.UTC),
+	}
+
+	sources := []EventSource{
+		&JsonEventSource{venue: Venue{Source: JsonSource, Id: "cafenineNH", Name: "Cafe Nine", Path: "testdata/events.json"}},
+		&JsonEventSource{venue: Venue{Source: JsonSource, Id: "missing", Name: "Missing", Path: "testdata/missing.json"}},
+	}
+
+	selection, err := NewTrackSelection(nil, defaultMarket, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolutions := resolver.ResolveVenues(fake, region, selection, sources, window, 1)
+
+	if len(resolutions[0].Errors) == 0 {
+		t.Errorf("Expected the refused searches to be kept")
+	}
+	for _, err := range resolutions[0].Errors {
+		if !strings.HasPrefix(err, "cafenineNH: Unable to search for ") {
+			t.Errorf("Expected a search error for the venue, got '%s'", err)
+		}
+	}
+
+	if len(resolutions[1].Errors) != 1 || !strings.HasPrefix(resolutions[1].Errors[0], "missing: Unable to get events: ") {
+		t.Errorf("Expected the venue's events to have failed, got %v", resolutions[1].Errors)
+	}
+}
+
+func TestTitleOverridesApplyToPerformers(t *testing.T) {
+	fake := newTestCatalog()
+	overrides := &ArtistOverrides{Overrides: []ArtistOverride{
+		{Match: "Trivia Night", Skip: true},
+		{Match: "Bowie Tribute Night", ArtistId: "bowie"},
+		{Match: "KCRW", ArtistId: "ryxno"},
+	}}
+	resolver := NewArtistResolver(nil, overrides, nil)
+	selection, err := NewTrackSelection(nil, defaultMarket, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		event    Event
+		expected []string
+	}{
+		{
+			name:     "performers",
+			event:    Event{Name: "Zombii Live", Artists: GuessArtistsForPerformers("Zombii Live", []string{"Zombii"})},
+			expected: []string{"Zombii headliner"},
+		},
+		{
+			name:     "title skipped",
+			event:    Event{Name: "Trivia Night", Artists: GuessArtistsForPerformers("Trivia Night", []string{"Zombii"})},
+			expected: []string{},
+		},
+		{
+			name:     "title pinned",
+			event:    Event{Name: "Bowie Tribute Night", Artists: GuessArtistsForPerformers("Bowie Tribute Night", []string{"Zombii"})},
+			expected: []string{"David Bowie headliner"},
+		},
+		{
+			name:     "presenter pinned",
+			event:    Event{Name: "KCRW presents Zombii", Artists: GuessArtistsForEvent("KCRW presents Zombii")},
+			expected: []string{"Zombii headliner", "RYXNO presenter"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := NewResolution(&Region{Region: "anywhere"}, selection)
+			res.Trace = make(map[*ArtistGuess]*GuessTrace)
+
+			actual := make([]string, 0)
+			for _, artist := range resolver.GetSpotifyArtists(fake, res, test.event) {
+				actual = append(actual, artist.Artist.Name+" "+artist.Role)
+			}
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/zmb3/spotify"
+)
+
+const (
+	TopTracksStrategy      = "top"
+	LatestReleaseStrategy  = "latest"
+	WeightedRandomStrategy = "random"
+	DeepCutsStrategy       = "deep"
+)
+
+const (
+	defaultHeadlinerTracks = 3
+	defaultSupportTracks   = 3
+	defaultDeepCutsSkip    = 3
+)
+
+// TrackOptions configures how a region picks tracks, Headliner and Support
+// are the number of tracks for headliners and for everyone else on the bill.
+type TrackOptions struct {
+	Strategy  string
+	Headliner int
+	Support   int
+	Skip      int
+}
+
+type TrackSelector interface {
+	SelectTracks(spotifyClient MusicService, artist *spotify.FullArtist, market string, count int) ([]spotify.FullTrack, error)
+}
+
+type TopTracksSelector struct {
+}
+
+type DeepCutsSelector struct {
+	Skip int
+}
+
+// WeightedRandomSelector favors popular tracks without always picking them.
+// Seeding by week keeps a playlist stable between runs in the same week.
+type WeightedRandomSelector struct {
+	Seed int64
+}
+
+// GetWeekSeed is the same from Sunday to Saturday, so every run in a week
+// picks the same tracks.
+func GetWeekSeed(t time.Time) int64 {
+	return GetLastSunday(t).Unix()
+}
+
+type LatestReleaseSelector struct {
+}
+
+type TrackSelection struct {
+	Selector  TrackSelector
+	Market    string
+	Headliner int
+	Support   int
+}
+
+func NewTrackSelector(options *TrackOptions, seed int64) (TrackSelector, error) {
+	strategy := TopTracksStrategy
+	if options != nil && options.Strategy != "" {
+		strategy = options.Strategy
+	}
+
+	switch strategy {
+	case TopTracksStrategy:
+		return &TopTracksSelector{}, nil
+	case LatestReleaseStrategy:
+		return &LatestReleaseSelector{}, nil
+	case WeightedRandomStrategy:
+		return &WeightedRandomSelector{Seed: seed}, nil
+	case DeepCutsStrategy:
+		skip := defaultDeepCutsSkip
+		if options.Skip > 0 {
+			skip = options.Skip
+		}
+		return &DeepCutsSelector{Skip: skip}, nil
+	}
+
+	return nil, fmt.Errorf("Unknown track strategy '%s'", strategy)
+}
+
+func NewTrackSelection(options *TrackOptions, market string, seed int64) (*TrackSelection, error) {
+	selector, err := NewTrackSelector(options, seed)
+	if err != nil {
+		return nil, err
+	}
+
+	selection := &TrackSelection{
+		Selector:  selector,
+		Market:    market,
+		Headliner: defaultHeadlinerTracks,
+		Support:   defaultSupportTracks,
+	}
+	if options != nil && options.Headliner > 0 {
+		selection.Headliner = options.Headliner
+	}
+	if options != nil && options.Support > 0 {
+		selection.Support = options.Support
+	}
+
+	return selection, nil
+}
+
+func (s *TrackSelection) Count(role string) int {
+	if role == HeadlinerRole {
+		return s.Headliner
+	}
+	return s.Support
+}
+
+func (s *TrackSelection) SelectTracks(spotifyClient MusicService, artist *spotify.FullArtist, role string) ([]spotify.FullTrack, error) {
+	return s.Selector.SelectTracks(spotifyClient, artist, s.Market, s.Count(role))
+}
+
+func (s *TopTracksSelector) SelectTracks(spotifyClient MusicService, artist *spotify.FullArtist, market string, count int) ([]spotify.FullTrack, error) {
+	topTracks, err := spotifyClient.GetArtistsTopTracks(artist.ID, market)
+	if err != nil {
+		return nil, err
+	}
+	if len(topTracks) > count {
+		return topTracks[:count], nil
+	}
+	return topTracks, nil
+}
+
+func (s *DeepCutsSelector) SelectTracks(spotifyClient MusicService, artist *spotify.FullArtist, market string, count int) ([]spotify.FullTrack, error) {
+	topTracks, err := spotifyClient.GetArtistsTopTracks(artist.ID, market)
+	if err != nil {
+		return nil, err
+	}
+
+	// Artists with only a handful of tracks have no deep cuts, the least
+	// popular of what there is will do.
+	skip := s.Skip
+	if skip > len(topTracks)-count {
+		skip = len(topTracks) - count
+	}
+	if skip < 0 {
+		skip = 0
+	}
+
+	return topTracks[skip:min(skip+count, len(topTracks))], nil
+}
+
+func (s *WeightedRandomSelector) SelectTracks(spotifyClient MusicService, artist *spotify.FullArtist, market string, count int) ([]spotify.FullTrack, error) {
+	topTracks, err := spotifyClient.GetArtistsTopTracks(artist.ID, market)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := fnv.New64a()
+	hash.Write([]byte(artist.ID))
+	random := rand.New(rand.NewSource(s.Seed ^ int64(hash.Sum64())))
+
+	remaining := make([]spotify.FullTrack, len(topTracks))
+	copy(remaining, topTracks)
+
+	tracks := make([]spotify.FullTrack, 0, count)
+	for len(tracks) < count && len(remaining) > 0 {
+		total := 0
+		for _, track := range remaining {
+			total += track.Popularity + 1
+		}
+
+		pick := random.Intn(total)
+		for i, track := range remaining {
+			pick -= track.Popularity + 1
+			if pick < 0 {
+				tracks = append(tracks, track)
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+		}
+	}
+
+	return tracks, nil
+}
+
+// SelectTracks takes tracks from the artist's newest albums and singles,
+// skipping tracks on them by other artists.
+func (s *LatestReleaseSelector) SelectTracks(spotifyClient MusicService, artist *spotify.FullArtist, market string, count int) ([]spotify.FullTrack, error) {
+	country := market
+	limit := 50
+	albumTypes := spotify.AlbumTypeAlbum | spotify.AlbumTypeSingle
+	page, err := spotifyClient.GetArtistAlbumsOpt(artist.ID, &spotify.Options{Country: &country, Limit: &limit}, &albumTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	albums := make([]spotify.SimpleAlbum, len(page.Albums))
+	copy(albums, page.Albums)
+	sort.SliceStable(albums, func(i, j int) bool {
+		return albums[i].ReleaseDateTime().After(albums[j].ReleaseDateTime())
+	})
+
+	tracks := make([]spotify.FullTrack, 0, count)
+	for _, album := range albums {
+		albumTracks, err := GetAlbumTracks(spotifyClient, album.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, track := range albumTracks {
+			if !HasArtist(track.Artists, artist.ID) {
+				continue
+			}
+			tracks = append(tracks, spotify.FullTrack{SimpleTrack: track, Album: album})
+			if len(tracks) == count {
+				return tracks, nil
+			}
+		}
+	}
+
+	return tracks, nil
+}
+
+// IsPlayableIn goes by is_playable when the track was fetched for a market and
+// falls back to the track's available markets.
+func IsPlayableIn(track spotify.FullTrack, market string) bool {
+	if track.IsPlayable != nil {
+		return *track.IsPlayable
+	}
+	if len(track.AvailableMarkets) == 0 {
+		return true
+	}
+	for _, available := range track.AvailableMarkets {
+		if available == market {
+			return true
+		}
+	}
+	return false
+}
+
+func HasArtist(artists []spotify.SimpleArtist, id spotify.ID) bool {
+	for _, artist := range artists {
+		if artist.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zmb3/spotify"
+)
+
+func TestWeightedRandomSelectorKeepsTheWeek(t *testing.T) {
+	artist := newTestArtist("zombii", "Zombii", 20, "punk")
+	fake := NewFakeMusicService("jlewalle")
+	for i := 0; i < 10; i++ {
+		track := newTestTrack(fmt.Sprintf("z%d", i), fmt.Sprintf("Song %d", i), artist)
+		track.Popularity = 10 * i
+		fake.TopTracks[artist.ID] = append(fake.TopTracks[artist.ID], track)
+	}
+
+	selectTracks := func(t *testing.T, day time.Time) []spotify.ID {
+		selector := &WeightedRandomSelector{Seed: GetWeekSeed(day)}
+		tracks, err := selector.SelectTracks(fake, &artist, defaultMarket, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return GetTrackIds(tracks)
+	}
+
+	sunday := time.Date(2017, 5, 28, 0, 0, 0, 0, time.Local)
+	expected := selectTracks(t, sunday)
+
+	tests := []struct {
+		name string
+		day  time.Time
+		same bool
+	}{
+		{"sunday afternoon", sunday.Add(15 * time.Hour), true},
+		{"thursday", time.Date(2017, 6, 1, 6, 29, 0, 0, time.Local), true},
+		{"saturday night", time.Date(2017, 6, 3, 23, 59, 0, 0, time.Local), true},
+		{"next sunday", time.Date(2017, 6, 4, 0, 0, 0, 0, time.Local), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if same := GetWeekSeed(test.day) == GetWeekSeed(sunday); same != test.same {
+				t.Errorf("Expected the same seed %v, got %v", test.same, same)
+			}
+			if test.same {
+				if actual := selectTracks(t, test.day); !reflect.DeepEqual(actual, expected) {
+					t.Errorf("Expected %v, got %v", expected, actual)
+				}
+			}
+		})
+	}
+}
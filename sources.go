@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	fb "github.com/huandu/facebook"
+)
+
+const (
+	FacebookSource = "facebook"
+	IcsSource      = "ics"
+	JsonSource     = "json"
+	JsonLdSource   = "jsonld"
+)
+
+type EventSource interface {
+	GetVenue() Venue
+	GetVenueName() (string, error)
+	GetUpcomingEvents(window Window) ([]Event, error)
+}
+
+type EventSources struct {
+	facebookSession *fb.Session
+}
+
+func NewEventSources() *EventSources {
+	return &EventSources{}
+}
+
+func (es *EventSources) NewEventSource(venue Venue) (EventSource, error) {
+	switch venue.Source {
+	case "", FacebookSource:
+		if es.facebookSession == nil {
+			session, err := AuthenticateFacebook()
+			if err != nil {
+				return nil, fmt.Errorf("Unable to authenticate with Facebook: %v", err)
+			}
+			es.facebookSession = session
+		}
+		return &FacebookEventSource{session: es.facebookSession, venue: venue}, nil
+	case IcsSource:
+		return &IcsEventSource{venue: venue}, nil
+	case JsonSource:
+		return &JsonEventSource{venue: venue}, nil
+	case JsonLdSource:
+		return &JsonLdEventSource{venue: venue}, nil
+	}
+
+	return nil, fmt.Errorf("Unknown event source '%s'", venue.Source)
+}
+
+func FilterUpcomingEvents(unfiltered []Event, window Window) (events []Event) {
+	for _, event := range unfiltered {
+		if window.Contains(event.StartTime) {
+			events = append(events, event)
+		}
+	}
+
+	return
+}
+
+func GetVenueDisplayName(venue Venue) string {
+	if venue.Name != "" {
+		return venue.Name
+	}
+	if venue.Id != "" {
+		return venue.Id
+	}
+	if venue.Url != "" {
+		return venue.Url
+	}
+	return venue.Path
+}
+
+// GetVenueKey identifies a venue across runs and regions, by its Id when the
+// regions file gives it one.
+func GetVenueKey(venue Venue) string {
+	if venue.Id != "" {
+		return venue.Id
+	}
+	return GetVenueDisplayName(venue)
+}
+
+func ReadVenueResource(venue Venue) ([]byte, error) {
+	if venue.Path != "" {
+		return ioutil.ReadFile(venue.Path)
+	}
+
+	if venue.Url == "" {
+		return nil, fmt.Errorf("Venue '%s' has no Url or Path", GetVenueDisplayName(venue))
+	}
+
+	r, err := http.Get(venue.Url)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error fetching %s: %s", venue.Url, r.Status)
+	}
+
+	return ioutil.ReadAll(r.Body)
+}
+
+type IcsEventSource struct {
+	venue Venue
+}
+
+func (s *IcsEventSource) GetVenue() Venue {
+	return s.venue
+}
+
+func (s *IcsEventSource) GetVenueName() (string, error) {
+	return GetVenueDisplayName(s.venue), nil
+}
+
+func (s *IcsEventSource) GetUpcomingEvents(window Window) (events []Event, err error) {
+	data, err := ReadVenueResource(s.venue)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar, err := ParseCalendar(data)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse calendar: %v", err)
+	}
+
+	name := GetVenueDisplayName(s.venue)
+	for _, ve := range calendar.Occurrences(window.From, window.To) {
+		venueName := name
+		if s.venue.Name == "" && ve.Location != "" {
+			venueName = ve.Location
+		}
+		events = append(events, Event{
+			Name:      ve.Summary,
+			StartTime: ve.Start,
+			Venue:     venueName,
+			TicketUrl: ve.Url,
+		})
+	}
+
+	return events, nil
+}
+
+type JsonEvent struct {
+	Name      string
+	StartTime time.Time
+	Venue     string
+	TicketUrl string
+}
+
+type JsonEventSource struct {
+	venue Venue
+}
+
+func (s *JsonEventSource) GetVenue() Venue {
+	return s.venue
+}
+
+func (s *JsonEventSource) GetVenueName() (string, error) {
+	return GetVenueDisplayName(s.venue), nil
+}
+
+func (s *JsonEventSource) GetUpcomingEvents(window Window) (events []Event, err error) {
+	data, err := ReadVenueResource(s.venue)
+	if err != nil {
+		return nil, err
+	}
+
+	var jsonEvents []JsonEvent
+	if err := json.Unmarshal(data, &jsonEvents); err != nil {
+		return nil, fmt.Errorf("Unable to parse events: %v", err)
+	}
+
+	for _, je := range jsonEvents {
+		venueName := je.Venue
+		if venueName == "" {
+			venueName = GetVenueDisplayName(s.venue)
+		}
+		events = append(events, Event{
+			Name:      je.Name,
+			StartTime: je.StartTime,
+			Venue:     venueName,
+			TicketUrl: je.TicketUrl,
+		})
+	}
+
+	return FilterUpcomingEvents(events, window), nil
+}
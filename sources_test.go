@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJsonEventSource(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer server.Close()
+
+	window := Window{
+		From: time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2017, 6, 8, 0, 0, 0, 0, time.UTC),
+	}
+
+	expected := []Event{
+		{
+			Name:      "Zombii",
+			StartTime: time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC),
+			Venue:     "The Space Upstairs",
+			TicketUrl: "https://example.com/zombii",
+		},
+		{
+			Name:      "RYXNO",
+			StartTime: time.Date(2017, 6, 7, 23, 0, 0, 0, time.UTC),
+			Venue:     "The Space",
+		},
+	}
+
+	for _, venue := range []Venue{
+		{Source: JsonSource, Name: "The Space", Path: "testdata/the-space.json"},
+		{Source: JsonSource, Name: "The Space", Url: server.URL + "/the-space.json"},
+	} {
+		source := &JsonEventSource{venue: venue}
+
+		name, err := source.GetVenueName()
+		if err != nil || name != "The Space" {
+			t.Errorf("Expected 'The Space', got '%s': %v", name, err)
+		}
+
+		events, err := source.GetUpcomingEvents(window)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(events, expected) {
+			t.Errorf("Expected %+v, got %+v", expected, events)
+		}
+	}
+
+	source := &JsonEventSource{venue: Venue{Source: JsonSource, Path: "testdata/recurring.ics"}}
+	if _, err := source.GetUpcomingEvents(window); err == nil || !strings.Contains(err.Error(), "Unable to parse events") {
+		t.Errorf("Expected a file that isn't json to fail, got %v", err)
+	}
+}
+
+func TestNewEventSource(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected EventSource
+		err      string
+	}{
+		{source: IcsSource, expected: &IcsEventSource{}},
+		{source: JsonSource, expected: &JsonEventSource{}},
+		{source: JsonLdSource, expected: &JsonLdEventSource{}},
+		{source: "myspace", err: "Unknown event source 'myspace'"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			venue := Venue{Source: test.source, Name: "The Space", Path: "testdata/the-space.json"}
+			source, err := NewEventSources().NewEventSource(venue)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("Expected '%s', got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reflect.TypeOf(source) != reflect.TypeOf(test.expected) {
+				t.Errorf("Expected a %T, got a %T", test.expected, source)
+			}
+			if source.GetVenue() != venue {
+				t.Errorf("Expected the source to be for %v, got %v", venue, source.GetVenue())
+			}
+		})
+	}
+}
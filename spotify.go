@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"math/rand"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -20,8 +22,24 @@ import (
 var (
 	authenticator = spotify.NewAuthenticator(spotifyRedirectUrl, spotify.ScopePlaylistModifyPrivate, spotify.ScopePlaylistModifyPublic, spotify.ScopeUserLibraryModify, spotify.ScopeUserReadPrivate)
 	clientChannel = make(chan *spotify.Client)
+	transport     = NewRateLimitedTransport(http.DefaultTransport)
 )
 
+func NewSpotifyClient(token *oauth2.Token) *spotify.Client {
+	config := &oauth2.Config{
+		ClientID:     spotifyClientId,
+		ClientSecret: spotifyClientSecret,
+		RedirectURL:  spotifyRedirectUrl,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  spotify.AuthURL,
+			TokenURL: spotify.TokenURL,
+		},
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: transport})
+	client := spotify.NewClient(config.Client(ctx, token))
+	return &client
+}
+
 func AuthenticateSpotify() (spotifyClient *spotify.Client, err error) {
 	var tokens = ReadTokens()
 
@@ -43,9 +61,7 @@ func AuthenticateSpotify() (spotifyClient *spotify.Client, err error) {
 		oauthToken.RefreshToken = tokens.Spotify.RefreshToken
 		oauthToken.Expiry, _ = time.Parse("Mon Jan 2 15:04:05 -0700 MST 2006", tokens.Spotify.Expiry)
 		oauthToken.TokenType = tokens.Spotify.TokenType
-		newClient := authenticator.NewClient(&oauthToken)
-		authenticator.SetAuthInfo(spotifyClientId, spotifyClientSecret)
-		spotifyClient = &newClient
+		spotifyClient = NewSpotifyClient(&oauthToken)
 	}
 
 	user, err := spotifyClient.CurrentUser()
@@ -77,11 +93,10 @@ func CompleteAuth(w http.ResponseWriter, r *http.Request) {
 	tokens.Spotify.TokenType = token.TokenType
 	WriteTokens(tokens)
 
-	client := authenticator.NewClient(token)
-	clientChannel <- &client
+	clientChannel <- NewSpotifyClient(token)
 }
 
-func GetPlaylistByTitle(spotifyClient *spotify.Client, user, name string) (*spotify.SimplePlaylist, error) {
+func GetPlaylistByTitle(spotifyClient MusicService, user, name string) (*spotify.SimplePlaylist, error) {
 	limit := 20
 	offset := 0
 	options := spotify.Options{Limit: &limit, Offset: &offset}
@@ -108,7 +123,7 @@ func GetPlaylistByTitle(spotifyClient *spotify.Client, user, name string) (*spot
 	return nil, nil
 }
 
-func GetPlaylist(spotifyClient *spotify.Client, user string, name string) (pl *spotify.SimplePlaylist, err error) {
+func GetPlaylist(spotifyClient MusicService, user string, name string) (pl *spotify.SimplePlaylist, err error) {
 	log.Printf("Looking for '%s'...", name)
 
 	pl, err = GetPlaylistByTitle(spotifyClient, user, name)
@@ -174,7 +189,7 @@ func (pu *PlaylistUpdate) Contains(id spotify.ID) bool {
 	return pu.idsBefore.Contains(id)
 }
 
-func GetArtistAlbums(spotifyClient *spotify.Client, id spotify.ID) ([]spotify.SimpleAlbum, error) {
+func GetArtistAlbums(spotifyClient MusicService, id spotify.ID) ([]spotify.SimpleAlbum, error) {
 	all := make([]spotify.SimpleAlbum, 0)
 	limit := 20
 	offset := 0
@@ -198,7 +213,7 @@ func GetArtistAlbums(spotifyClient *spotify.Client, id spotify.ID) ([]spotify.Si
 	return all, nil
 }
 
-func GetAlbumTracks(spotifyClient *spotify.Client, id spotify.ID) ([]spotify.SimpleTrack, error) {
+func GetAlbumTracks(spotifyClient MusicService, id spotify.ID) ([]spotify.SimpleTrack, error) {
 	all := make([]spotify.SimpleTrack, 0)
 	limit := 20
 	offset := 0
@@ -220,7 +235,7 @@ func GetAlbumTracks(spotifyClient *spotify.Client, id spotify.ID) ([]spotify.Sim
 	return all, nil
 }
 
-func GetPlaylistTracks(spotifyClient *spotify.Client, id spotify.ID) ([]spotify.PlaylistTrack, error) {
+func GetPlaylistTracks(spotifyClient MusicService, id spotify.ID) ([]spotify.PlaylistTrack, error) {
 	all := make([]spotify.PlaylistTrack, 0)
 	limit := 100
 	offset := 0
@@ -244,7 +259,7 @@ func GetPlaylistTracks(spotifyClient *spotify.Client, id spotify.ID) ([]spotify.
 	return all, nil
 }
 
-func RemoveAllPlaylistTracks(spotifyClient *spotify.Client, id spotify.ID) error {
+func RemoveAllPlaylistTracks(spotifyClient MusicService, id spotify.ID) error {
 	tracks, err := GetPlaylistTracks(spotifyClient, id)
 	if err != nil {
 		return err
@@ -366,7 +381,7 @@ func (ts *TracksSet) Sample(number int) (ns *TracksSet) {
 	}
 }
 
-func RemoveTracksFromPlaylist(spotifyClient *spotify.Client, id spotify.ID, ids []spotify.ID) (err error) {
+func RemoveTracksFromPlaylist(spotifyClient MusicService, id spotify.ID, ids []spotify.ID) (err error) {
 	for i := 0; i < len(ids); i += 50 {
 		batch := ids[i:min(i+50, len(ids))]
 		_, err := spotifyClient.RemoveTracksFromPlaylist(id, batch...)
@@ -378,7 +393,7 @@ func RemoveTracksFromPlaylist(spotifyClient *spotify.Client, id spotify.ID, ids
 	return nil
 }
 
-func AddTracksToPlaylist(spotifyClient *spotify.Client, id spotify.ID, ids []spotify.ID) (err error) {
+func AddTracksToPlaylist(spotifyClient MusicService, id spotify.ID, ids []spotify.ID) (err error) {
 	for i := 0; i < len(ids); i += 50 {
 		batch := ids[i:min(i+50, len(ids))]
 		_, err := spotifyClient.AddTracksToPlaylist(id, batch...)
@@ -390,11 +405,138 @@ func AddTracksToPlaylist(spotifyClient *spotify.Client, id spotify.ID, ids []spo
 	return nil
 }
 
-func RemoveTracksSetFromPlaylist(spotifyClient *spotify.Client, id spotify.ID, ts *TracksSet) (err error) {
+// ReorderPlaylistTracks moves the playlist's tracks into order with as few
+// Spotify reorders as possible. Tracks missing from order are left at the end.
+func ReorderPlaylistTracks(spotifyClient MusicService, id spotify.ID, order []spotify.ID) (err error) {
+	tracks, err := GetPlaylistTracks(spotifyClient, id)
+	if err != nil {
+		return fmt.Errorf("Error reordering tracks: %v", err)
+	}
+
+	moves := PlanReorder(GetTrackIdsFromPlaylistTracks(tracks), order)
+	snapshot := ""
+	for _, move := range moves {
+		move.SnapshotID = snapshot
+		snapshot, err = spotifyClient.ReorderPlaylistTracks(id, move)
+		if err != nil {
+			return fmt.Errorf("Error reordering tracks: %v", err)
+		}
+	}
+
+	log.Printf("Moved %d tracks", len(moves))
+
+	return nil
+}
+
+// PlanReorder is the moves that turn current into order. The longest run of
+// tracks already in the right order relative to each other stays put and
+// every other track is moved once, to just after the track that precedes it
+// in order. A track that's in the playlist twice is matched up in order.
+func PlanReorder(current []spotify.ID, order []spotify.ID) []spotify.PlaylistReorderOptions {
+	// wanted is positions in current, in the order they should end up in.
+	used := make([]bool, len(current))
+	wanted := make([]int, 0, len(current))
+	for _, want := range order {
+		for i, id := range current {
+			if id == want && !used[i] {
+				used[i] = true
+				wanted = append(wanted, i)
+				break
+			}
+		}
+	}
+	for i := range current {
+		if !used[i] {
+			wanted = append(wanted, i)
+		}
+	}
+
+	keep := LongestIncreasing(wanted)
+
+	// playlist is the original position of the track at each position.
+	playlist := make([]int, len(current))
+	for i := range playlist {
+		playlist[i] = i
+	}
+	indexOf := func(original int) int {
+		for i, p := range playlist {
+			if p == original {
+				return i
+			}
+		}
+		return -1
+	}
+
+	moves := make([]spotify.PlaylistReorderOptions, 0)
+	for k, original := range wanted {
+		if keep[k] {
+			continue
+		}
+
+		from := indexOf(original)
+		insertBefore := 0
+		if k > 0 {
+			insertBefore = indexOf(wanted[k-1]) + 1
+		}
+		if insertBefore == from || insertBefore == from+1 {
+			continue
+		}
+
+		moves = append(moves, spotify.PlaylistReorderOptions{
+			RangeStart:   from,
+			RangeLength:  1,
+			InsertBefore: insertBefore,
+		})
+
+		to := insertBefore
+		if to > from {
+			to--
+		}
+		playlist = append(playlist[:from], playlist[from+1:]...)
+		playlist = append(playlist[:to], append([]int{original}, playlist[to:]...)...)
+	}
+
+	return moves
+}
+
+// LongestIncreasing marks the values making up the longest strictly
+// increasing subsequence of values.
+func LongestIncreasing(values []int) []bool {
+	// tails[l] is the index of the smallest value ending an increasing
+	// subsequence of length l+1, previous links each index to the one before
+	// it in its subsequence.
+	tails := make([]int, 0)
+	previous := make([]int, len(values))
+	for i, value := range values {
+		l := sort.Search(len(tails), func(j int) bool {
+			return values[tails[j]] >= value
+		})
+		if l > 0 {
+			previous[i] = tails[l-1]
+		} else {
+			previous[i] = -1
+		}
+		if l == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[l] = i
+		}
+	}
+
+	keep := make([]bool, len(values))
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = previous[i] {
+			keep[i] = true
+		}
+	}
+	return keep
+}
+
+func RemoveTracksSetFromPlaylist(spotifyClient MusicService, id spotify.ID, ts *TracksSet) (err error) {
 	return RemoveTracksFromPlaylist(spotifyClient, id, ts.ToArray())
 }
 
-func AddTracksSetToPlaylist(spotifyClient *spotify.Client, id spotify.ID, ts *TracksSet) (err error) {
+func AddTracksSetToPlaylist(spotifyClient MusicService, id spotify.ID, ts *TracksSet) (err error) {
 	return AddTracksToPlaylist(spotifyClient, id, ts.ToArray())
 }
 
@@ -494,7 +636,7 @@ func MapIds(ids []spotify.ID) (ifaces []interface{}) {
 	return
 }
 
-func SetPlaylistTracks(spotifyClient *spotify.Client, id spotify.ID, tracks []spotify.ID) error {
+func SetPlaylistTracks(spotifyClient MusicService, id spotify.ID, tracks []spotify.ID) error {
 	err := RemoveAllPlaylistTracks(spotifyClient, id)
 	if err != nil {
 		return fmt.Errorf("Error getting removing tracks: %v", err)
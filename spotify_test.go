@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/zmb3/spotify"
+)
+
+func newTestTrack(id string, name string, artist spotify.FullArtist) spotify.FullTrack {
+	track := spotify.FullTrack{}
+	track.ID = spotify.ID(id)
+	track.Name = name
+	track.Artists = []spotify.SimpleArtist{artist.SimpleArtist}
+	return track
+}
+
+func newTestPlaylist(fake *FakeMusicService, owner string, id string, name string, tracks ...spotify.FullTrack) *FakePlaylist {
+	pl := &FakePlaylist{Tracks: make([]spotify.PlaylistTrack, 0)}
+	pl.Playlist.ID = spotify.ID(id)
+	pl.Playlist.Name = name
+	pl.Playlist.Owner.ID = owner
+	for _, track := range tracks {
+		pl.Tracks = append(pl.Tracks, spotify.PlaylistTrack{Track: track})
+	}
+	fake.Playlists = append(fake.Playlists, pl)
+	return pl
+}
+
+func TestGetPlaylistByTitle(t *testing.T) {
+	tests := []struct {
+		name      string
+		playlists int
+		title     string
+		expected  spotify.ID
+		failing   bool
+	}{
+		{name: "first page", playlists: 5, title: "playlist 3", expected: "pl3"},
+		{name: "ignores case", playlists: 5, title: "PLAYLIST 3", expected: "pl3"},
+		{name: "later page", playlists: 45, title: "playlist 42", expected: "pl42"},
+		{name: "missing after a full page", playlists: 20, title: "playlist 20"},
+		{name: "someone else's", playlists: 5, title: "theirs"},
+		{name: "no playlists", playlists: 0, title: "playlist 0"},
+		{name: "spotify fails", playlists: 5, title: "playlist 3", failing: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := NewFakeMusicService("jlewalle")
+			for i := 0; i < test.playlists; i++ {
+				newTestPlaylist(fake, "jlewalle", fmt.Sprintf("pl%d", i), fmt.Sprintf("Playlist %d", i))
+			}
+			newTestPlaylist(fake, "someone", "theirs", "Theirs")
+			if test.failing {
+				fake.RateLimitEvery = 1
+			}
+
+			playlist, err := GetPlaylistByTitle(fake, "jlewalle", test.title)
+			if test.failing {
+				if err == nil {
+					t.Errorf("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var actual spotify.ID
+			if playlist != nil {
+				actual = playlist.ID
+			}
+			if actual != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, actual)
+			}
+		})
+	}
+}
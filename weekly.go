@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bytes"
 	"flag"
 	"fmt"
 	"io"
@@ -12,116 +11,50 @@ import (
 	"strings"
 	"time"
 
-	fb "github.com/huandu/facebook"
-
 	"github.com/zmb3/spotify"
 )
 
 type Event struct {
-	Name     string
-	Location string
-	Artists  *ArtistGuess
-}
-
-type ArtistResolver struct {
-	artistCache    map[string]*spotify.FullArtist
-	spotifyArtists map[string]*spotify.FullArtist
+	Name       string
+	StartTime  time.Time
+	Venue      string
+	TicketUrl  string
+	Performers []string
+	Artists    *ArtistGuess
 }
 
-func ProcessVenue(facebookSession *fb.Session, venueId string) (events []Event) {
-	venueInfo, _ := GetVenueInformation(facebookSession, venueId)
-	log.Println(venueInfo.Name)
-
-	facebookEvents, _ := GetUpcomingFacebookEvents(facebookSession, venueId)
-	for _, event := range facebookEvents {
-		artists := GuessArtistsForEvent(event.Name)
-		events = append(events, Event{
-			Name:     event.Name,
-			Location: "",
-			Artists:  artists,
-		})
+func ProcessVenue(source EventSource, window Window, res *Resolution) (venueName string, events []Event) {
+	venueName, err := source.GetVenueName()
+	if err != nil {
+		res.AddError(fmt.Errorf("Unable to get venue: %v", err))
 	}
+	res.Log.Println(venueName)
 
-	return
-}
-
-func NewArtistResolver() (resolver *ArtistResolver) {
-	resolver = new(ArtistResolver)
-	resolver.artistCache = make(map[string]*spotify.FullArtist)
-	resolver.spotifyArtists = make(map[string]*spotify.FullArtist)
-
-	return resolver
-}
-
-func (resolver *ArtistResolver) SearchWithRetry(spotifyClient *spotify.Client, st spotify.SearchType, term string) (sr *spotify.SearchResult, err error) {
-	country := "US"
-	for {
-		sr, err = spotifyClient.SearchOpt(term, st, &spotify.Options{Country: &country})
-		if err != nil {
-			if !strings.Contains(err.Error(), "rate") {
-				break
-			}
-
-			log.Printf("Throttled...")
-			time.Sleep(1 * time.Second)
-		} else {
-			break
-		}
+	upcoming, err := source.GetUpcomingEvents(window)
+	if err != nil {
+		res.AddError(fmt.Errorf("Unable to get events: %v", err))
 	}
-	return
-}
-
-func (resolver *ArtistResolver) GetSpotifyArtistsForGuess(spotifyClient *spotify.Client, depth int, artist *ArtistGuess) {
-	log.Printf("      [%-4s]%s%s\n", artist.Step, strings.Repeat("  ", depth), artist.Name)
-
-	anyFound := false
 
-	if resolver.artistCache[artist.Name] == nil {
-		found, err := resolver.SearchWithRetry(spotifyClient, spotify.SearchTypeArtist, artist.Name)
-		if err != nil {
-			log.Printf("Error: %v", err)
+	for _, event := range upcoming {
+		if len(event.Performers) > 0 {
+			event.Artists = GuessArtistsForPerformers(event.Name, event.Performers)
 		} else {
-			if found.Artists != nil {
-				for _, item := range found.Artists.Artists {
-					if strings.ToLower(item.Name) == strings.ToLower(artist.Name) {
-						log.Printf("      [%-4s]%s%s\n", "****", strings.Repeat("  ", depth), item.Name)
-						resolver.artistCache[artist.Name] = &item
-						resolver.spotifyArtists[artist.Name] = &item
-						anyFound = true
-						break
-					}
-				}
-			}
+			event.Artists = GuessArtistsForEvent(event.Name)
 		}
-	} else {
-		resolver.spotifyArtists[artist.Name] = resolver.artistCache[artist.Name]
+		events = append(events, event)
 	}
 
-	if !anyFound {
-		for _, child := range artist.Children {
-			resolver.GetSpotifyArtistsForGuess(spotifyClient, depth+1, child)
-		}
-	}
-}
-
-func (resolver *ArtistResolver) GetSpotifyArtists(spotifyClient *spotify.Client, event Event) (spotifyArtists map[string]*spotify.FullArtist) {
-	resolver.spotifyArtists = make(map[string]*spotify.FullArtist)
-
-	resolver.GetSpotifyArtistsForGuess(spotifyClient, 0, event.Artists)
-
-	spotifyArtists = resolver.spotifyArtists
-
 	return
 }
 
-func (resolver *ArtistResolver) GetArtistTracks(spotifyClient *spotify.Client, artist spotify.FullArtist) (tracks []spotify.FullTrack) {
-	topTracks, _ := spotifyClient.GetArtistsTopTracks(artist.ID, "US")
-	if len(topTracks) > 3 {
-		tracks = topTracks[:3]
-	} else {
-		tracks = topTracks
+// SetupLog writes the log to logFile and the console. Commands that print
+// json keep stdout for it and log to stderr instead.
+func SetupLog(logFile io.Writer, command string) {
+	console := os.Stdout
+	if command == "explain" || command == "history" {
+		console = os.Stderr
 	}
-	return
+	log.SetOutput(io.MultiWriter(logFile, console))
 }
 
 func GetFullTracks(tracks []spotify.PlaylistTrack) (fullTracks []spotify.FullTrack) {
@@ -133,9 +66,25 @@ func GetFullTracks(tracks []spotify.PlaylistTrack) (fullTracks []spotify.FullTra
 }
 
 type Options struct {
-	GuessOnly    bool
-	EclecticOnly bool
-	RegionsFile  string
+	GuessOnly              bool
+	EclecticOnly           bool
+	RegionsFile            string
+	Window                 WindowOptions
+	ArtistCacheFile        string
+	ArtistCacheTTL         time.Duration
+	ArtistCacheNegativeTTL time.Duration
+	OverridesFile          string
+	MatchThreshold         float64
+	DryRun                 bool
+	PlanFile               string
+	ApplyPlan              string
+	Offline                string
+	MaxAttempts            int
+	RequestBudget          int
+	Workers                int
+	LedgerFile             string
+	ConfigFile             string
+	HistoryFile            string
 }
 
 var nonLetters = regexp.MustCompile("[\\W\\D]")
@@ -174,46 +123,49 @@ func GetLastSunday(t time.Time) time.Time {
 	}
 }
 
-func UpdateEclectic(spotifyClient *spotify.Client, e *Eclectic24) error {
+func PlanEclectic(spotifyClient MusicService, user string, names NamesConfig, e *Eclectic24) (*PlaylistPlan, error) {
 	week := GetLastSunday(e.Show)
-	name := fmt.Sprintf("mbe %s", week.Format("06/01/02"))
+	name, err := RenderPlaylistName(names.Eclectic, NewPlaylistName("", eclecticStation, e.Show))
+	if err != nil {
+		return nil, err
+	}
 
 	log.Printf("Generating %v", name)
-	playlist, err := GetPlaylistByTitle(spotifyClient, "jlewalle", name)
+	playlist, err := GetPlaylistByTitle(spotifyClient, user, name)
 	if err != nil {
-		log.Fatalf("Unable to get playlist: %v", err)
+		return nil, fmt.Errorf("Unable to get playlist: %v", err)
 	}
 
-	var playlistId spotify.ID
-	if playlist == nil {
-		log.Printf("Creating %v", name)
-
-		created, err := spotifyClient.CreatePlaylistForUser("jlewalle", name, "", true)
-		if err != nil {
-			log.Fatalf("Unable to create playlist: %v", err)
-		}
-
-		playlistId = created.ID
-	} else {
-		playlistId = playlist.ID
+	pp := &PlaylistPlan{
+		Title:  name,
+		User:   user,
+		Add:    make([]PlannedTrack, 0),
+		Remove: make([]PlannedTrack, 0),
 	}
 
-	tracksBefore, _ := GetPlaylistTracks(spotifyClient, playlistId)
-	idsBefore := GetTrackIds(GetFullTracks(tracksBefore))
+	idsBefore := []spotify.ID{}
+	if playlist != nil {
+		pp.PlaylistId = playlist.ID
+		tracksBefore, _ := GetPlaylistTracks(spotifyClient, playlist.ID)
+		idsBefore = GetTrackIds(GetFullTracks(tracksBefore))
+	}
 	update := NewPlaylistUpdate(idsBefore)
 
 	log.Printf("Before: %d", len(idsBefore))
 
-	ar := NewArtistResolver()
+	ar := NewArtistResolver(nil, nil, nil)
 
 	for {
 		pl, err := NewPlaylistTracks(e.Show)
 		if err != nil {
-			return fmt.Errorf("Error getting tracks: %v", err)
+			return nil, fmt.Errorf("Error getting tracks: %v", err)
 		}
 
 		fmt.Printf("%v %v\n", len(*pl.Tracks), e.Show)
 
+		show := fmt.Sprintf("Eclectic24 %s", e.Show.Format("Mon Jan 2 15:04"))
+		selected := make(map[spotify.ID]spotify.FullTrack)
+
 		for _, track := range *pl.Tracks {
 			if track.AffiliateLinkSpotify != "" {
 
@@ -222,24 +174,24 @@ func UpdateEclectic(spotifyClient *spotify.Client, e *Eclectic24) error {
 
 				log.Printf("%v - %s", track.Artist, track.Title)
 
-				f, err := ar.SearchWithRetry(spotifyClient, spotify.SearchTypeTrack, query)
+				f, err := ar.Search(spotifyClient, defaultMarket, spotify.SearchTypeTrack, query)
 				if err != nil {
-					return fmt.Errorf("Error finding track: %v", err)
+					return nil, fmt.Errorf("Error finding track: %v", err)
 				}
 
 				sel := SelectTrack(track, f.Tracks.Tracks)
 				if sel != nil {
 					update.AddTrack(sel.ID)
+					selected[sel.ID] = *sel
 				}
 			}
 		}
 
 		idsToAdd := update.GetIdsToAdd().ToArray()
-		log.Printf("Adding %d tracks to %s", len(idsToAdd), playlistId)
+		log.Printf("Adding %d tracks to %s", len(idsToAdd), name)
 
-		for i := 0; i < len(idsToAdd); i += 50 {
-			batch := idsToAdd[i:min(i+50, len(idsToAdd))]
-			spotifyClient.AddTracksToPlaylist(playlistId, batch...)
+		for _, id := range idsToAdd {
+			pp.Add = append(pp.Add, NewPlannedTrack(selected[id], show))
 		}
 
 		update.MergeBeforeAndToAdd()
@@ -247,109 +199,384 @@ func UpdateEclectic(spotifyClient *spotify.Client, e *Eclectic24) error {
 		again := len(idsToAdd) > 0 && len(*pl.Tracks) > 0
 		if !again {
 			log.Printf("No new tracks, done")
-			return nil
+			return pp, nil
 		}
 
 		e.PreviousShow()
 
 		if GetLastSunday(e.Show) != week {
 			log.Printf("Got to the beginning of the week, done")
-			return nil
+			return pp, nil
 		}
 	}
 }
 
+// RegionRun is what every region in a run shares.
+type RegionRun struct {
+	Client   MusicService
+	Resolver *ArtistResolver
+	Sources  *EventSources
+	Config   *Config
+	Owner    string
+	Ledger   *Ledger
+	Plan     *Plan
+	Options  Options
+	Now      time.Time
+}
+
+// Window is the region's own window unless one was given on the command line.
+func (run *RegionRun) Window(region Region) Window {
+	return run.Options.Window.Override(region.Window).Resolve(run.Now)
+}
+
+// UpdateRegion resolves the region's venues and plans its playlists, applying
+// the plans unless it's a dry run. Venues and searches that fail and changes
+// Spotify refuses are kept in the report's Errors, anything else stops the
+// region.
+func (run *RegionRun) UpdateRegion(region Region) (*RegionReport, error) {
+	started := time.Now()
+	window := run.Window(region)
+	log.Printf("%s: %v", region.Region, window)
+
+	title, err := RenderPlaylistName(run.Config.Names.Region, NewPlaylistName(region.Region, "", window.From))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to name playlist: %v", err)
+	}
+
+	playlist, err := GetPlaylistByTitle(run.Client, run.Owner, title)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get playlist: %v", err)
+	}
+	tracksAfter := []PlannedTrack{}
+
+	// Event sources are created up front, the Facebook session is
+	// authenticated lazily by the first one that needs it.
+	sources := make([]EventSource, 0)
+	venueErrors := make([]string, 0)
+	for _, venue := range region.GetVenues() {
+		source, err := run.Sources.NewEventSource(venue)
+		if err != nil {
+			log.Printf("Unable to get events: %v", err)
+			venueErrors = append(venueErrors, fmt.Sprintf("%s: %v", GetVenueDisplayName(venue), err))
+			continue
+		}
+		sources = append(sources, source)
+	}
+
+	selection, err := NewTrackSelection(region.Tracks, region.GetMarket(), GetWeekSeed(window.From))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to select tracks for %s: %v", region.Region, err)
+	}
+	if err := ValidateOrder(region.Order); err != nil {
+		return nil, fmt.Errorf("Unable to order %s: %v", region.Region, err)
+	}
+
+	nearMisses := make([]NearMiss, 0)
+	ambiguities := make([]Ambiguity, 0)
+	unplayable := make([]PlannedTrack, 0)
+	venueNames := make([]string, 0)
+	resolutions := run.Resolver.ResolveVenues(run.Client, &region, selection, sources, window, run.Options.Workers)
+	for _, res := range resolutions {
+		log.Writer().Write(res.Buffer.Bytes())
+		tracksAfter = append(tracksAfter, res.Tracks()...)
+		nearMisses = append(nearMisses, res.NearMisses...)
+		ambiguities = append(ambiguities, res.Ambiguities...)
+		unplayable = append(unplayable, res.Unplayable...)
+		venueErrors = append(venueErrors, res.Errors...)
+		if res.VenueName != "" {
+			venueNames = append(venueNames, res.VenueName)
+		}
+	}
+
+	if len(nearMisses) > 0 {
+		log.Printf("Near misses:")
+		for _, nearMiss := range nearMisses {
+			log.Printf("   %.2f '%s' ~ '%s' (%s) at %s", nearMiss.Score, nearMiss.Guess, nearMiss.Candidate, nearMiss.ArtistId, nearMiss.Venue)
+		}
+		log.Printf("")
+	}
+
+	if len(ambiguities) > 0 {
+		log.Printf("Ambiguous:")
+		for _, ambiguity := range ambiguities {
+			log.Printf("   '%s' at %s: %s", ambiguity.Guess, ambiguity.Venue, ambiguity.Reason)
+			for _, candidate := range ambiguity.Candidates {
+				log.Printf("      %s", candidate)
+			}
+		}
+		log.Printf("")
+	}
+
+	if len(unplayable) > 0 {
+		log.Printf("Not playable in %s:", region.GetMarket())
+		for _, track := range unplayable {
+			log.Printf("   %s - %s (%s) [%s]", track.Artist, track.Title, track.Id, track.Event)
+		}
+		log.Printf("")
+	}
+
+	regionReport := &RegionReport{
+		Region:      region.Region,
+		Playlist:    title,
+		Window:      window,
+		Venues:      resolutions,
+		NearMisses:  nearMisses,
+		Ambiguities: ambiguities,
+		Unplayable:  unplayable,
+		Errors:      venueErrors,
+	}
+	if playlist != nil {
+		regionReport.PlaylistId = playlist.ID
+	}
+
+	if !run.Options.GuessOnly {
+		pp, err := NewPlaylistPlan(run.Client, run.Owner, title, playlist, tracksAfter, region.Order, NewRetentionPolicy(region.Retention, run.Ledger, run.Now))
+		if err != nil {
+			return nil, fmt.Errorf("Unable to plan playlist: %v", err)
+		}
+
+		pp.Description = DescribeRegionPlaylist(venueNames, window)
+		pp.Public = region.Public
+
+		run.Plan.Add(pp)
+
+		if !run.Options.DryRun {
+			if err := pp.Apply(run.Client, run.Ledger); err != nil {
+				log.Printf("Unable to update '%s': %v", title, err)
+				regionReport.Errors = append(regionReport.Errors, fmt.Sprintf("Unable to update '%s': %v", title, err))
+			}
+		}
+
+		regionReport.PlaylistId = pp.PlaylistId
+		regionReport.Added = pp.Add
+		regionReport.Removed = pp.Remove
+	}
+
+	if region.Archive != nil && !run.Options.GuessOnly {
+		archives, err := PlanArchives(run.Client, run.Owner, run.Config.Names, &region, window, tracksAfter)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to plan archives: %v", err)
+		}
+
+		for _, pp := range archives {
+			run.Plan.Add(pp)
+
+			if !run.Options.DryRun {
+				if err := pp.Apply(run.Client, run.Ledger); err != nil {
+					log.Printf("Unable to update '%s': %v", pp.Title, err)
+					regionReport.Errors = append(regionReport.Errors, fmt.Sprintf("Unable to update '%s': %v", pp.Title, err))
+				}
+			}
+		}
+	}
+
+	regionReport.Elapsed = time.Since(started)
+
+	return regionReport, nil
+}
+
 func main() {
 	var options Options
 
 	flag.BoolVar(&options.GuessOnly, "guess-only", false, "test guessing code only")
 	flag.BoolVar(&options.EclecticOnly, "eclectic-only", false, "only update mbe playlist")
 	flag.StringVar(&options.RegionsFile, "regions-file", "regions.json", "json regions file to use")
+	flag.StringVar(&options.ConfigFile, "config-file", "config.json", "json file with the playlist owner and names")
+	flag.IntVar(&options.Window.Days, "days", defaultWindowDays, "number of days of upcoming events to collect")
+	flag.Var(&options.Window.From, "from", "collect events starting on this date (YYYY-MM-DD)")
+	flag.Var(&options.Window.To, "to", "collect events up to and including this date (YYYY-MM-DD)")
+	flag.StringVar(&options.ArtistCacheFile, "artist-cache", "artist-cache.json", "json file to cache artist searches in")
+	flag.DurationVar(&options.ArtistCacheTTL, "artist-cache-ttl", defaultArtistCacheTTL, "how long to trust a cached artist match")
+	flag.DurationVar(&options.ArtistCacheNegativeTTL, "artist-cache-negative-ttl", defaultArtistCacheNegativeTTL, "how long to trust a cached failed artist search")
+	flag.StringVar(&options.OverridesFile, "overrides-file", "overrides.json", "json file of manual artist overrides")
+	flag.Float64Var(&options.MatchThreshold, "match-threshold", defaultMatchThreshold, "minimum score (0-1) for a search result to match a guess")
+	flag.BoolVar(&options.DryRun, "dry-run", false, "plan playlist changes without making them")
+	flag.StringVar(&options.PlanFile, "plan-file", "plan.json", "json file to write the dry run plan to")
+	flag.StringVar(&options.ApplyPlan, "apply-plan", "", "apply a plan written by --dry-run and exit")
+	flag.StringVar(&options.Offline, "offline", "", "use an in-memory Spotify loaded from this json catalog")
+	flag.IntVar(&options.MaxAttempts, "max-attempts", defaultMaxAttempts, "attempts per Spotify request before giving up when throttled")
+	flag.IntVar(&options.RequestBudget, "request-budget", defaultRequestBudget, "maximum number of Spotify requests per run, 0 for no limit")
+	flag.IntVar(&options.Workers, "workers", defaultWorkers, "number of venues to resolve at the same time")
+	flag.StringVar(&options.LedgerFile, "ledger-file", "ledger.json", "json file recording when and why tracks were added")
+	flag.StringVar(&options.HistoryFile, "history-file", "history.jsonl", "json lines file to append a record of each run to")
 
 	flag.Parse()
 
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "days", "from", "to":
+			options.Window.Explicit = true
+		}
+	})
+
 	logFile, err := os.OpenFile("weekly.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
 	if err != nil {
 		log.Fatalf("Error opening file: %v", err)
 	}
 	defer logFile.Close()
 
-	buffer := new(bytes.Buffer)
+	SetupLog(logFile, flag.Arg(0))
 
-	multi := io.MultiWriter(logFile, buffer, os.Stdout)
+	if flag.Arg(0) == "history" {
+		if err := HistoryCommand(options.HistoryFile, flag.Args()[1:], time.Now()); err != nil {
+			log.Fatalf("Unable to read history: %v", err)
+		}
 
-	log.SetOutput(multi)
+		return
+	}
 
-	spotifyClient, _ := AuthenticateSpotify()
-	artistsResolver := NewArtistResolver()
+	transport.MaxAttempts = options.MaxAttempts
+	transport.Budget = options.RequestBudget
+
+	var spotifyClient MusicService
+	if options.Offline != "" {
+		spotifyClient, err = LoadFakeMusicService(options.Offline)
+		if err != nil {
+			log.Fatalf("Unable to load catalog: %v", err)
+		}
+	} else {
+		spotifyClient, err = AuthenticateSpotify()
+		if err != nil {
+			log.Fatalf("Unable to authenticate: %v", err)
+		}
+	}
+
+	config, err := LoadConfig(options.ConfigFile)
+	if err != nil {
+		log.Fatalf("Unable to load config: %v", err)
+	}
+
+	notifiers, err := NewNotifiers(config.Notifiers)
+	if err != nil {
+		log.Fatalf("Unable to configure notifiers: %v", err)
+	}
+
+	owner, err := config.GetOwner(spotifyClient)
+	if err != nil {
+		log.Fatalf("Unable to get owner: %v", err)
+	}
+
+	ledger, err := LoadLedger(options.LedgerFile)
+	if err != nil {
+		log.Fatalf("Unable to load ledger: %v", err)
+	}
+
+	if flag.Arg(0) == "archives" {
+		if err := ArchivesCommand(spotifyClient, owner, ledger, flag.Args()[1:], options.DryRun); err != nil {
+			log.Fatalf("Unable to prune archives: %v", err)
+		}
+
+		return
+	}
+
+	if options.ApplyPlan != "" {
+		plan, err := LoadPlan(options.ApplyPlan)
+		if err != nil {
+			log.Fatalf("Unable to load plan: %v", err)
+		}
+
+		err = plan.Apply(spotifyClient, ledger)
+		if saveErr := ledger.Save(); saveErr != nil {
+			log.Printf("Unable to save ledger: %v", saveErr)
+		}
+		if err != nil {
+			log.Fatalf("Unable to apply plan: %v", err)
+		}
+
+		return
+	}
+
+	artistCache, err := LoadArtistCache(options.ArtistCacheFile, options.ArtistCacheTTL, options.ArtistCacheNegativeTTL)
+	if err != nil {
+		log.Fatalf("Unable to load artist cache: %v", err)
+	}
+	overrides, err := LoadArtistOverrides(options.OverridesFile)
+	if err != nil {
+		log.Fatalf("Unable to load overrides: %v", err)
+	}
+	artistsResolver := NewArtistResolver(artistCache, overrides, NewArtistMatcher(options.MatchThreshold))
+
+	if flag.Arg(0) == "explain" {
+		if err := ExplainCommand(spotifyClient, artistsResolver, options.RegionsFile, flag.Args()[1:]); err != nil {
+			log.Fatalf("Unable to explain: %v", err)
+		}
+
+		return
+	}
+	plan := NewPlan()
+
+	now := time.Now()
+	report := NewRunReport(now, options.DryRun)
 
 	if !options.EclecticOnly {
-		facebookSession, _ := AuthenticateFacebook()
 		regions := LoadRegions(options.RegionsFile)
 
+		run := &RegionRun{
+			Client:   spotifyClient,
+			Resolver: artistsResolver,
+			Sources:  NewEventSources(),
+			Config:   config,
+			Owner:    owner,
+			Ledger:   ledger,
+			Plan:     plan,
+			Options:  options,
+			Now:      now,
+		}
+
 		for _, region := range regions {
-			title := region.Region + " weekly"
-			playlist, err := GetPlaylistByTitle(spotifyClient, "jlewalle", title)
+			regionReport, err := run.UpdateRegion(region)
 			if err != nil {
-				log.Fatalf("Unable to get playlist: %v\n", err)
-			}
-			tracksAfter := []spotify.FullTrack{}
-
-			for _, venueId := range region.VenueIds {
-				for _, event := range ProcessVenue(facebookSession, venueId) {
-					foundTracks := false
-					log.Printf("   '%s'\n", event.Name)
-					artists := artistsResolver.GetSpotifyArtists(spotifyClient, event)
-					for _, value := range artists {
-						artistTracks := artistsResolver.GetArtistTracks(spotifyClient, *value)
-						tracksAfter = append(tracksAfter, artistTracks...)
-						log.Printf("   %d tracks '%s'\n", len(artistTracks), value.Name)
-						foundTracks = true
-					}
-					if !foundTracks {
-						log.Printf("   NO TRACKS")
-					}
-
-					log.Printf("")
-				}
+				log.Printf("ERROR: %v", err)
+				regionReport = &RegionReport{Region: region.Region, Window: run.Window(region), Errors: []string{err.Error()}}
 			}
+			report.Add(regionReport)
+		}
 
-			if playlist == nil {
-				log.Printf("No existing playlist '%s'", title)
-			} else if !options.GuessOnly {
-				idsAfter := GetTrackIds(tracksAfter)
-
-				tracksBefore, _ := GetPlaylistTracks(spotifyClient, playlist.ID)
-				idsBefore := GetTrackIds(GetFullTracks(tracksBefore))
-				update := NewPlaylistUpdate(idsBefore)
+		if err := artistCache.Save(); err != nil {
+			log.Printf("Unable to save artist cache: %v", err)
+		}
+	}
 
-				for _, id := range idsAfter {
-					update.AddTrack(id)
-				}
+	e := NewEclectic24()
+	pp, err := PlanEclectic(spotifyClient, owner, config.Names, e)
+	if err != nil {
+		log.Printf("ERROR: Unable to update eclectic: %v", err)
+		report.AddError(fmt.Sprintf("Unable to update eclectic: %v", err))
+	} else {
+		plan.Add(pp)
+	}
 
-				idsToAddSlice := update.GetIdsToAdd().ToArray()
-				idsToRemoveSlice := update.GetIdsToRemove().ToArray()
+	if options.DryRun {
+		plan.Print(os.Stdout)
 
-				for i := 0; i < len(idsToRemoveSlice); i += 50 {
-					batch := idsToRemoveSlice[i:min(i+50, len(idsToRemoveSlice))]
-					spotifyClient.RemoveTracksFromPlaylist(playlist.ID, batch...)
-				}
+		if err := plan.Write(options.PlanFile); err != nil {
+			log.Fatalf("Unable to write plan: %v", err)
+		}
 
-				for i := 0; i < len(idsToAddSlice); i += 50 {
-					batch := idsToAddSlice[i:min(i+50, len(idsToAddSlice))]
-					spotifyClient.AddTracksToPlaylist(playlist.ID, batch...)
-				}
-			}
+		log.Printf("Wrote %s, apply with --apply-plan %s", options.PlanFile, options.PlanFile)
+	} else if pp != nil {
+		if err := pp.Apply(spotifyClient, ledger); err != nil {
+			log.Printf("ERROR: Unable to update eclectic: %v", err)
+			report.AddError(fmt.Sprintf("Unable to update eclectic: %v", err))
 		}
+	}
+
+	if err := ledger.Save(); err != nil {
+		log.Printf("Unable to save ledger: %v", err)
+	}
 
-		if !options.GuessOnly {
-			SendEmail(buffer.String())
+	if !options.EclecticOnly {
+		if err := AppendHistory(options.HistoryFile, NewHistoryRecord(report, time.Now())); err != nil {
+			log.Printf("Unable to write history: %v", err)
 		}
 	}
 
-	e := NewEclectic24()
-	err = UpdateEclectic(spotifyClient, e)
-	if err != nil {
-		log.Fatalf("Unable to update eclectic: %v\n", err)
+	if !options.GuessOnly && !options.DryRun {
+		SendEmail(config.Email, report)
+		Notify(notifiers, report)
+	}
+
+	if report.HasErrors() {
+		os.Exit(1)
 	}
 }
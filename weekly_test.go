@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zmb3/spotify"
+)
+
+func newTestCatalog() *FakeMusicService {
+	fake := NewFakeMusicService("jlewalle")
+
+	zombii := newTestArtist("zombii", "Zombii", 20, "punk")
+	ryxno := newTestArtist("ryxno", "RYXNO", 10, "noise")
+	bowie := newTestArtist("bowie", "David Bowie", 90, "rock")
+	fake.Artists = []spotify.FullArtist{zombii, ryxno, bowie}
+
+	fake.TopTracks[zombii.ID] = []spotify.FullTrack{
+		newTestTrack("z1", "Brains", zombii),
+		newTestTrack("z2", "Shamble", zombii),
+	}
+	fake.TopTracks[ryxno.ID] = []spotify.FullTrack{
+		newTestTrack("r1", "Static", ryxno),
+	}
+	fake.Tracks = []spotify.FullTrack{
+		newTestTrack("heroes", "Heroes", bowie),
+		newTestTrack("starman", "Starman", bowie),
+		newTestTrack("old", "Old Song", bowie),
+	}
+
+	return fake
+}
+
+func getTestPlaylistTrackIds(pl *FakePlaylist) []spotify.ID {
+	ids := make([]spotify.ID, 0)
+	for _, track := range pl.Tracks {
+		ids = append(ids, track.Track.ID)
+	}
+	return ids
+}
+
+func TestPlanEclectic(t *testing.T) {
+	heroes := PlaylistTrack{Artist: "David Bowie", Title: "Heroes", AffiliateLinkSpotify: "spotify:search:david+bowie+heroes"}
+	starman := PlaylistTrack{Artist: "David Bowie", Title: "Starman", AffiliateLinkSpotify: "spotify:search:david+bowie+starman"}
+	unlinked := PlaylistTrack{Artist: "Nobody", Title: "Nothing"}
+
+	thursday := time.Date(2017, 6, 1, 12, 0, 0, 0, time.Local)
+	sunday := time.Date(2017, 5, 28, 0, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name     string
+		show     time.Time
+		shows    map[string][]PlaylistTrack
+		existing []spotify.ID
+		expected []spotify.ID
+	}{
+		{
+			name: "new playlist",
+			show: thursday,
+			shows: map[string][]PlaylistTrack{
+				"2017/06/01 12:00": {heroes, unlinked, starman},
+				"2017/06/01 09:00": {heroes},
+			},
+			expected: []spotify.ID{"heroes", "starman"},
+		},
+		{
+			name: "existing playlist",
+			show: thursday,
+			shows: map[string][]PlaylistTrack{
+				"2017/06/01 12:00": {heroes, starman},
+			},
+			existing: []spotify.ID{"heroes"},
+			expected: []spotify.ID{"starman"},
+		},
+		{
+			name: "goes back until a show adds nothing",
+			show: thursday,
+			shows: map[string][]PlaylistTrack{
+				"2017/06/01 12:00": {heroes},
+				"2017/06/01 09:00": {starman},
+				"2017/06/01 06:00": {},
+			},
+			expected: []spotify.ID{"heroes", "starman"},
+		},
+		{
+			name: "stops at the beginning of the week",
+			show: sunday,
+			shows: map[string][]PlaylistTrack{
+				"2017/05/28 00:00": {heroes},
+				"2017/05/27 21:00": {starman},
+			},
+			expected: []spotify.ID{"heroes"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tracks, ok := test.shows[r.URL.Path[1:]+" "+r.URL.Query().Get("time")]
+				if !ok {
+					tracks = []PlaylistTrack{}
+				}
+				json.NewEncoder(w).Encode(tracks)
+			}))
+			defer server.Close()
+
+			defer func(original string) { tracklistUrl = original }(tracklistUrl)
+			tracklistUrl = server.URL + "/"
+
+			fake := newTestCatalog()
+			names := NewConfig().Names
+			title, err := RenderPlaylistName(names.Eclectic, NewPlaylistName("", eclecticStation, test.show))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.existing != nil {
+				pl := newTestPlaylist(fake, "jlewalle", "mbe", title)
+				for _, id := range test.existing {
+					track, _ := fake.FindTrack(id)
+					pl.Tracks = append(pl.Tracks, spotify.PlaylistTrack{Track: *track})
+				}
+			}
+
+			pp, err := PlanEclectic(fake, "jlewalle", names, &Eclectic24{Show: test.show})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if pp.Title != title {
+				t.Errorf("Expected '%s', got '%s'", title, pp.Title)
+			}
+			if (test.existing != nil) != (pp.PlaylistId == "mbe") {
+				t.Errorf("Expected the existing playlist, got '%s'", pp.PlaylistId)
+			}
+			if actual := GetPlannedTrackIds(pp.Add); !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestUpdateRegion(t *testing.T) {
+	now := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	region := Region{
+		Region:    "new haven",
+		Retention: &RetentionOptions{Days: 14},
+		Venues: []Venue{
+			{Source: JsonSource, Name: "Cafe Nine", Path: "testdata/events.json"},
+			{Source: "myspace", Name: "Nowhere"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		options   Options
+		existing  []spotify.ID
+		planned   []spotify.ID
+		removed   []spotify.ID
+		playlist  []spotify.ID
+		plans     int
+		unchanged bool
+	}{
+		{
+			name:      "dry run",
+			options:   Options{DryRun: true},
+			planned:   []spotify.ID{"z1", "z2", "r1"},
+			plans:     1,
+			unchanged: true,
+		},
+		{
+			name:     "creates the playlist",
+			options:  Options{},
+			planned:  []spotify.ID{"z1", "z2", "r1"},
+			playlist: []spotify.ID{"z1", "z2", "r1"},
+			plans:    1,
+		},
+		{
+			name:     "updates the playlist",
+			options:  Options{},
+			existing: []spotify.ID{"old", "z2"},
+			planned:  []spotify.ID{"z1", "r1"},
+			removed:  []spotify.ID{"old"},
+			playlist: []spotify.ID{"z2", "z1", "r1"},
+			plans:    1,
+		},
+		{
+			name:      "guess only",
+			options:   Options{GuessOnly: true},
+			plans:     0,
+			unchanged: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newTestCatalog()
+			if test.existing != nil {
+				pl := newTestPlaylist(fake, "jlewalle", "weekly", "new haven weekly")
+				for _, id := range test.existing {
+					track, _ := fake.FindTrack(id)
+					pl.Tracks = append(pl.Tracks, spotify.PlaylistTrack{Track: *track})
+				}
+			}
+			playlistsBefore := len(fake.Playlists)
+
+			run := &RegionRun{
+				Client:   fake,
+				Resolver: NewArtistResolver(nil, nil, nil),
+				Sources:  NewEventSources(),
+				Config:   NewConfig(),
+				Owner:    "jlewalle",
+				Ledger:   NewLedger(),
+				Plan:     NewPlan(),
+				Options:  test.options,
+				Now:      now,
+			}
+
+			report, err := run.UpdateRegion(region)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if report.Playlist != "new haven weekly" {
+				t.Errorf("Unexpected report for '%s'", report.Playlist)
+			}
+			if expected := []string{"Nowhere: Unknown event source 'myspace'"}; !reflect.DeepEqual(report.Errors, expected) {
+				t.Errorf("Expected errors %v, got %v", expected, report.Errors)
+			}
+			if counts := report.Counts(); counts.Events != 3 || counts.Unresolved != 1 {
+				t.Errorf("Expected 3 events with 1 unresolved, got %+v", counts)
+			}
+			if len(run.Plan.Playlists) != test.plans {
+				t.Fatalf("Expected %d plans, got %d", test.plans, len(run.Plan.Playlists))
+			}
+			if test.plans > 0 {
+				pp := run.Plan.Playlists[0]
+				if actual := GetPlannedTrackIds(pp.Add); !reflect.DeepEqual(actual, test.planned) {
+					t.Errorf("Expected to add %v, got %v", test.planned, actual)
+				}
+				if actual := GetPlannedTrackIds(pp.Remove); !reflect.DeepEqual(actual, test.removed) {
+					t.Errorf("Expected to remove %v, got %v", test.removed, actual)
+				}
+			}
+
+			if test.unchanged {
+				if len(fake.Playlists) != playlistsBefore {
+					t.Errorf("Expected no playlists to be created")
+				}
+				return
+			}
+
+			playlist, err := GetPlaylistByTitle(fake, "jlewalle", "new haven weekly")
+			if err != nil || playlist == nil {
+				t.Fatalf("Expected the playlist to exist: %v", err)
+			}
+			pl, _ := fake.FindPlaylist(playlist.ID)
+			if actual := getTestPlaylistTrackIds(pl); !reflect.DeepEqual(actual, test.playlist) {
+				t.Errorf("Expected the playlist to have %v, got %v", test.playlist, actual)
+			}
+			if pl.Description == "" {
+				t.Errorf("Expected the playlist to be described")
+			}
+			if entry := run.Ledger.Get(playlist.ID, "z1"); entry == nil || entry.Event == "" {
+				t.Errorf("Expected the ledger to know why z1 was added, got %v", entry)
+			}
+		})
+	}
+}
+
+func TestUpdateRegionKeepsVenueErrors(t *testing.T) {
+	region := Region{
+		Region: "new haven",
+		Venues: []Venue{
+			{Source: JsonSource, Name: "Cafe Nine", Path: "testdata/events.json"},
+			{Source: JsonSource, Name: "Missing", Path: "testdata/missing.json"},
+		},
+	}
+
+	run := &RegionRun{
+		Client:   newTestCatalog(),
+		Resolver: NewArtistResolver(nil, nil, nil),
+		Sources:  NewEventSources(),
+		Config:   NewConfig(),
+		Owner:    "jlewalle",
+		Ledger:   NewLedger(),
+		Plan:     NewPlan(),
+		Options:  Options{GuessOnly: true},
+		Now:      time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	rr, err := run.UpdateRegion(region)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rr.Errors) != 1 {
+		t.Fatalf("Expected the missing venue's error, got %v", rr.Errors)
+	}
+
+	report := NewRunReport(run.Now, false)
+	report.Add(rr)
+	record := NewHistoryRecord(report, run.Now)
+	if !reflect.DeepEqual(record.Regions[0].Errors, rr.Errors) {
+		t.Errorf("Expected the history to have %v, got %v", rr.Errors, record.Regions[0].Errors)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const defaultWindowDays = 7
+
+type Window struct {
+	From time.Time
+	To   time.Time
+}
+
+// WindowOptions' Explicit is set when they came from the command line, which
+// wins over a region's window.
+type WindowOptions struct {
+	Days     int
+	From     DateOnly
+	To       DateOnly
+	Explicit bool `json:"-"`
+}
+
+// Contains includes From, so events on the first day that only have a date
+// count.
+func (w Window) Contains(t time.Time) bool {
+	return !t.Before(w.From) && t.Before(w.To)
+}
+
+func (w Window) String() string {
+	return fmt.Sprintf("%s - %s", w.From.Format("2006/01/02 15:04"), w.To.Format("2006/01/02 15:04"))
+}
+
+func (wo WindowOptions) Override(other *WindowOptions) WindowOptions {
+	if other == nil || wo.Explicit {
+		return wo
+	}
+	if other.Days > 0 || other.From.IsSet() || other.To.IsSet() {
+		return *other
+	}
+	return wo
+}
+
+func (wo WindowOptions) Resolve(now time.Time) Window {
+	from := now
+	if wo.From.IsSet() {
+		from = time.Date(wo.From.Year(), wo.From.Month(), wo.From.Day(), 0, 0, 0, 0, now.Location())
+	}
+
+	if wo.To.IsSet() {
+		to := time.Date(wo.To.Year(), wo.To.Month(), wo.To.Day(), 0, 0, 0, 0, now.Location())
+		return Window{From: from, To: to.AddDate(0, 0, 1)}
+	}
+
+	days := wo.Days
+	if days <= 0 {
+		days = defaultWindowDays
+	}
+
+	return Window{From: from, To: from.AddDate(0, 0, days)}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowResolve(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2017, 6, 1, 6, 29, 36, 0, newYork)
+
+	date := func(s string) DateOnly {
+		value, err := time.Parse(doLayout, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return DateOnly{value}
+	}
+
+	tests := []struct {
+		name     string
+		options  WindowOptions
+		region   *WindowOptions
+		expected Window
+	}{
+		{
+			name:     "defaults to a week from now",
+			options:  WindowOptions{},
+			expected: Window{From: now, To: now.AddDate(0, 0, 7)},
+		},
+		{
+			name:     "days",
+			options:  WindowOptions{Days: 30},
+			expected: Window{From: now, To: now.AddDate(0, 0, 30)},
+		},
+		{
+			name:    "from and to include the whole last day",
+			options: WindowOptions{From: date("2017-06-09"), To: date("2017-06-11")},
+			expected: Window{
+				From: time.Date(2017, 6, 9, 0, 0, 0, 0, newYork),
+				To:   time.Date(2017, 6, 12, 0, 0, 0, 0, newYork),
+			},
+		},
+		{
+			name:    "from without to uses days",
+			options: WindowOptions{Days: 2, From: date("2017-06-09")},
+			expected: Window{
+				From: time.Date(2017, 6, 9, 0, 0, 0, 0, newYork),
+				To:   time.Date(2017, 6, 11, 0, 0, 0, 0, newYork),
+			},
+		},
+		{
+			name:     "region replaces the defaults",
+			options:  WindowOptions{Days: defaultWindowDays},
+			region:   &WindowOptions{Days: 30},
+			expected: Window{From: now, To: now.AddDate(0, 0, 30)},
+		},
+		{
+			name:     "region without a window",
+			options:  WindowOptions{Days: defaultWindowDays},
+			region:   &WindowOptions{},
+			expected: Window{From: now, To: now.AddDate(0, 0, 7)},
+		},
+		{
+			name:     "command line wins over the region",
+			options:  WindowOptions{Days: 3, Explicit: true},
+			region:   &WindowOptions{From: date("2017-06-09"), To: date("2017-06-11")},
+			expected: Window{From: now, To: now.AddDate(0, 0, 3)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual := test.options.Override(test.region).Resolve(now)
+			if !actual.From.Equal(test.expected.From) || !actual.To.Equal(test.expected.To) {
+				t.Errorf("Expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	from := time.Date(2017, 6, 9, 0, 0, 0, 0, time.UTC)
+	window := Window{From: from, To: from.AddDate(0, 0, 3)}
+
+	tests := []struct {
+		name     string
+		time     time.Time
+		expected bool
+	}{
+		{"before", from.Add(-time.Second), false},
+		{"from", from, true},
+		{"during", from.Add(36 * time.Hour), true},
+		{"just before to", window.To.Add(-time.Second), true},
+		{"to", window.To, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := window.Contains(test.time); actual != test.expected {
+				t.Errorf("Expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}